@@ -1,57 +1,165 @@
 package cmd
 
 import (
-	"embed"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ForkbombEu/fouter"
 	slangroom "github.com/dyne/slangroom-exec/bindings/go"
 	"github.com/forkbombeu/twinroom/cmd/httpserver"
 	"github.com/forkbombeu/twinroom/cmd/utils"
+	jsschema "github.com/santhosh-tekuri/jsonschema/v5"
 	"github.com/spf13/cobra"
 )
 
-var contracts embed.FS
+var contracts fs.FS
+var manifest *utils.Manifest
 var daemon bool
 var port string
+var dryRun bool
+var listFormat string
+var outputOptions utils.OutputOptions
+var outFile string
+var execTimeout time.Duration
+var verbose bool
+var inputFlags utils.InputFlags
+var mergeDiagnostics bool
+var profile string
+var bundle string
+
+// bundleFlag is the --bundle flag found before the command, when the contracts are loaded
+var bundleFlag string
+
+// fromBundle is true when the contracts are read from a bundle in place of the embedded ones
+var fromBundle bool
+var trustSecrets bool
 
 // runCmd is the base command when called without any subcommands.
-func Execute(embeddedFiles embed.FS) {
+func Execute(embeddedFiles fs.FS) {
 	contracts = embeddedFiles
+	// The commands are created before the flags are parsed, so the bundle is looked up by hand
+	var err error
+	if bundleFlag, err = utils.BundleArg(runCmd.PersistentFlags(), os.Args[1:]); err != nil {
+		fail(ExitUsage, "Error: %v\n", err)
+	}
+	bundlePath := bundleFlag
+	if bundlePath == "" {
+		bundlePath = os.Getenv(utils.BundleEnv)
+	}
+	if bundlePath != "" {
+		bundleFS, err := utils.OpenBundle(bundlePath)
+		if err != nil {
+			fail(ExitNotFound, "Error: %v\n", err)
+		}
+		contracts = bundleFS
+		fromBundle = true
+	}
 
-	// Dynamically add commands for each embedded file
+	// The manifest generated at build time spares the parsing and the introspection of the contracts
+	if manifest, err = utils.ReadManifest(contracts, "contracts"); err != nil {
+		log.Printf("WARNING: %v\n", err)
+	}
+	utils.UseManifest(manifest)
+
+	// Dynamically add commands for each embedded file, and for the contract of a folder
 	addEmbeddedFileCommands()
+	runCmd.SetArgs(addFolderCommand(os.Args[1:]))
 
 	// Execute the root command
 	if err := runCmd.Execute(); err != nil {
-		log.Println(err)
-		os.Exit(1)
+		fail(exitCode(err), "%v\n", err)
 	}
 }
 func init() {
 	runCmd.AddCommand(listCmd)
 	// Add a flag for the daemon mode to the 'list' command
 	listCmd.Flags().BoolVarP(&daemon, "daemon", "", false, "Start HTTP server to list slangroom files")
+	// The --output flag of the root command is the format of the contract output
+	listCmd.Flags().StringVarP(&listFormat, "format", "f", "", "Print the details of each contract as json, yaml or table")
 	runCmd.PersistentFlags().BoolVarP(&daemon, "daemon", "", false, "Start HTTP server to execute slangroom file")
 	runCmd.PersistentFlags().StringVarP(&port, "port", "", "8080", "Port to use when running in daemon mode")
+	runCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "", false, "Print the input that would be sent to slangroom without executing the contract")
+	runCmd.PersistentFlags().StringVarP(&outputOptions.Format, "output", "", "json", "Format of the contract output: json, pretty, yaml or raw")
+	runCmd.PersistentFlags().StringVarP(&outputOptions.Select, "select", "", "", "Print only the value at a JSON pointer (/result/0) or path (result[0]) of the contract output")
+	runCmd.PersistentFlags().BoolVarP(&outputOptions.Raw, "raw", "", false, "Print string values without quotes")
+	runCmd.PersistentFlags().StringVarP(&outFile, "out-file", "", "", "Write the contract output to a file instead of the standard output")
+	runCmd.PersistentFlags().DurationVarP(&execTimeout, "exec-timeout", "", 0, "Stop the contract if it does not end in time, like 30s (default no timeout)")
+	runCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "", false, "Add the zenroom heap and trace to the error when the contract fails")
+	runCmd.PersistentFlags().StringVarP(&inputFlags.Data, "data", "", "", "Data of the contract as inline JSON, @path of a JSON file or - for stdin")
+	runCmd.PersistentFlags().StringVarP(&inputFlags.Keys, "keys", "", "", "Keys of the contract as inline JSON, @path of a JSON file or - for stdin")
+	runCmd.PersistentFlags().StringVarP(&inputFlags.Extra, "extra", "", "", "Extra of the contract as inline JSON, @path of a JSON file or - for stdin")
+	runCmd.PersistentFlags().StringVarP(&inputFlags.Context, "context", "", "", "Context of the contract as inline JSON, @path of a JSON file or - for stdin")
+	runCmd.PersistentFlags().StringVarP(&inputFlags.Conf, "conf", "", "", "Conf of the contract as inline JSON, @path of a JSON file or - for stdin")
+	runCmd.PersistentFlags().StringVarP(&inputFlags.Merge.Mode, "merge", "", utils.MergeDeep, "How the input of the contract is merged: deep, shallow or patch (RFC 7396)")
+	runCmd.PersistentFlags().StringVarP(&inputFlags.Merge.Arrays, "merge-arrays", "", utils.ArraysReplace, "How the deep merge combines two arrays: replace, append or index")
+	runCmd.PersistentFlags().StringVarP(&bundle, "bundle", "", "", "Archive of contracts (.zip, .tar.gz or .tgz) used in place of the embedded ones (default $"+utils.BundleEnv+")")
+	runCmd.PersistentFlags().StringVarP(&profile, "profile", "", "", "Profile of the side files to merge over the base ones, like staging (default $"+utils.ProfileEnv+")")
+	runCmd.PersistentFlags().BoolVarP(&mergeDiagnostics, "merge-diagnostics", "", false, "Print which source won for each value of the input of the contract")
+	runCmd.PersistentFlags().BoolVarP(&trustSecrets, utils.TrustSecretsFlag, "", false, "Let the contracts of a folder or of a bundle run the secret commands and read the secret files outside of their folder")
+	runCmd.AddCommand(inspectCmd)
+}
+
+// inspectCmd prints the input that a contract would receive, it is the same as running the contract
+// command with the --dry-run flag. The contract is either an embedded one, like test/param, or a folder
+// followed by the path of the contract in it. The arguments are replaced by addFolderCommand, this
+// command only runs to print its help.
+var inspectCmd = &cobra.Command{
+	Use:                "inspect <contract> [arguments and flags of the contract]",
+	Short:              "Show the input that would be sent to slangroom for a contract without executing it",
+	Args:               cobra.MinimumNArgs(1),
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if err := cmd.Help(); err != nil {
+			log.Println("Error:", err)
+		}
+	},
 }
 
 // listCmd is a command that lists all slangroom files in the folder or list embedded files if no folder is specified.
-// It accepts an optional daemon flag to start an HTTP server for listing the files.
+// With the format flag it prints the details of each contract in a machine readable format, and with the
+// daemon flag it serves them as JSON over HTTP.
 var listCmd = &cobra.Command{
 	Use:   "list [folder]",
 	Short: "List all contracts in the folder or list embedded contracts if no folder is specified",
 	Args:  cobra.MaximumNArgs(1),
+	PreRunE: func(_ *cobra.Command, _ []string) error {
+		switch listFormat {
+		case "", "json", "yaml", "table":
+			return nil
+		default:
+			return fmt.Errorf("invalid format %s, use json, yaml or table", listFormat)
+		}
+	},
 	Run: func(_ *cobra.Command, args []string) {
+		if listFormat != "" || daemon {
+			contractsInfo, err := describeContracts(args)
+			if err != nil {
+				log.Println("Error:", err)
+				os.Exit(ExitFailure)
+			}
+			if daemon {
+				if err := httpserver.StartListServer(port, contractsInfo); err != nil {
+					log.Printf("Failed to start HTTP server: %v\n", err)
+					os.Exit(ExitFailure)
+				}
+				return
+			}
+			if err := printContracts(contractsInfo); err != nil {
+				log.Println("Error:", err)
+				os.Exit(ExitFailure)
+			}
+			return
+		}
 		if len(args) == 0 {
 			// If no folder argument is provided, list embedded files
 			fmt.Println("Listing embedded slangroom files:")
-			err := fouter.CreateFileRouter("", &contracts, "contracts", func(file fouter.SlangFile) {
+			err := utils.WalkContracts(contracts, "contracts", func(file fouter.SlangFile) {
 				relativePath := strings.TrimPrefix(filepath.Join(file.Dir, file.FileName), "contracts/")
 				relativePath = strings.TrimSuffix(relativePath, filepath.Ext(relativePath))
 				fmt.Printf("Found file: %s\n", relativePath)
@@ -75,11 +183,72 @@ var listCmd = &cobra.Command{
 	},
 }
 
+// describeContracts collects the details of the contracts in the folder, or of the embedded ones
+// if no folder is given.
+func describeContracts(args []string) ([]utils.ContractInfo, error) {
+	contractsInfo := []utils.ContractInfo{}
+	var describeErr error
+	describe := func(fsys fs.FS, file fouter.SlangFile, relativePath string, source string) {
+		if describeErr != nil {
+			return
+		}
+		info, err := utils.DescribeContract(fsys, filepath.ToSlash(file.Dir), filepath.ToSlash(relativePath), file.Content, source)
+		if err != nil {
+			describeErr = err
+			return
+		}
+		contractsInfo = append(contractsInfo, info)
+	}
+
+	var err error
+	if len(args) == 0 {
+		source := utils.ContractSourceEmbedded
+		if fromBundle {
+			source = utils.ContractSourceBundle
+		}
+		err = utils.WalkContracts(contracts, "contracts", func(file fouter.SlangFile) {
+			relativePath := strings.TrimPrefix(filepath.Join(file.Dir, file.FileName), "contracts/")
+			describe(contracts, file, strings.TrimSuffix(relativePath, filepath.Ext(relativePath)), source)
+		})
+	} else {
+		folder := os.DirFS(args[0])
+		err = fouter.CreateFileRouter(args[0], nil, "", func(file fouter.SlangFile) {
+			relativePath := filepath.Join(file.Dir, file.FileName)
+			describe(folder, file, strings.TrimSuffix(relativePath, filepath.Ext(relativePath)), utils.ContractSourceDisk)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return contractsInfo, describeErr
+}
+
+// printContracts prints the details of the contracts in the format chosen with --format
+func printContracts(contractsInfo []utils.ContractInfo) error {
+	switch listFormat {
+	case "yaml":
+		document, err := utils.ToYAML(contractsInfo)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(document))
+	case "table":
+		return utils.WriteContractsTable(os.Stdout, contractsInfo)
+	default:
+		document, err := utils.ToJSON(contractsInfo)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(document))
+	}
+	return nil
+}
+
 // Function to add commands for each embedded slangroom file
 func addEmbeddedFileCommands() {
 	dirCommands := make(map[string]*cobra.Command)
 
-	err := fouter.CreateFileRouter("", &contracts, "contracts", func(file fouter.SlangFile) {
+	err := utils.WalkContracts(contracts, "contracts", func(file fouter.SlangFile) {
 		relativePath := strings.TrimPrefix(filepath.Join(file.Dir, file.FileName), "contracts/")
 		relativePath = strings.TrimSuffix(relativePath, filepath.Ext(relativePath))
 
@@ -94,19 +263,27 @@ func addEmbeddedFileCommands() {
 				dirCmd := &cobra.Command{
 					Use:   strings.ReplaceAll(dirPath, string(os.PathSeparator), " "),
 					Short: fmt.Sprintf("Commands for files in %s", dirPath),
+					// Arguments left after the subcommands lookup name a contract that does not exist
+					Args: func(cmd *cobra.Command, args []string) error {
+						if len(args) > 0 {
+							return &exitError{code: ExitNotFound, err: fmt.Errorf("unknown contract %q for %q", args[0], cmd.CommandPath())}
+						}
+						return nil
+					},
 				}
 				dirCmd.Run = func(_ *cobra.Command, _ []string) {
 					if daemon {
 						httpInput := httpserver.HTTPInput{
 							BinaryName:     filepath.Base(os.Args[0]),
-							EmbeddedFolder: &contracts,
+							EmbeddedFolder: contracts,
 							EmbeddedPath:   "contracts",
 							EmbeddedSubDir: dirPath,
+							Manifest:       manifest,
 							Port:           port,
 						}
 						if err := httpserver.StartHTTPServer(httpInput); err != nil {
 							log.Printf("Failed to start HTTP server: %v\n", err)
-							os.Exit(1)
+							os.Exit(ExitFailure)
 						}
 						return
 					}
@@ -117,66 +294,96 @@ func addEmbeddedFileCommands() {
 			parentCmd = dirCommands[dirPath]
 		}
 
-		// Create the command for the file
-		fileCmd := &cobra.Command{
-			Use:   fileCmdName,
-			Short: fmt.Sprintf("Execute the embedded contract %s", strings.TrimSuffix(file.FileName, filepath.Ext(file.FileName))),
-		}
-		var isMetadata bool
-		argContents := make(map[string]interface{})
-		flagContents := make(map[string]utils.FlagData)
+		// Add the file command to its directory's command
+		parentCmd.AddCommand(newContractCommand(file, fileCmdName, ""))
+	})
+
+	if err != nil {
+		log.Println("Error adding embedded file commands:", err)
+	}
+}
 
-		input := slangroom.SlangroomInput{Contract: file.Content}
+// newContractCommand creates the command that executes a contract, with the arguments and flags
+// of its metadata or of its introspection. The folder is empty for the embedded contracts.
+func newContractCommand(file fouter.SlangFile, fileCmdName string, folder string) *cobra.Command {
+	contractName := strings.TrimSuffix(file.FileName, filepath.Ext(file.FileName))
+	fileCmd := &cobra.Command{
+		Use:   fileCmdName,
+		Short: fmt.Sprintf("Execute the embedded contract %s", contractName),
+	}
+	metadataFS := contracts
+	metadataPath := filepath.Join(file.Dir, contractName+".metadata.json")
+	if folder != "" {
+		fileCmd.Short = fmt.Sprintf("Execute the contract %s", contractName)
+		metadataFS = nil
+		metadataPath = filepath.Join(folder, metadataPath)
+	}
+	var isMetadata bool
+	argContents := make(map[string]interface{})
+	flagContents := make(map[string]utils.FlagData)
+
+	input := slangroom.SlangroomInput{Contract: file.Content}
 
-		metadataPath := filepath.Join(file.Dir, strings.TrimSuffix(file.FileName, filepath.Ext(file.FileName))+".metadata.json")
-		metadata, err := utils.LoadMetadata(&contracts, metadataPath)
-		if err != nil && err.Error() != "metadata file not found" {
-			log.Printf("WARNING: error in metadata for contracts: %s\n", fileCmdName)
+	var metadata *utils.CommandMetadata
+	var inputSchema *jsschema.Schema
+	var err error
+	if entry, ok := manifest.Entry(filepath.Join(file.Dir, file.FileName), file.Content); folder == "" && ok {
+		metadata, err = entry.LoadMetadata()
+	} else {
+		metadata, err = utils.LoadMetadata(metadataFS, metadataPath)
+	}
+	var metadataErr error
+	if err != nil && err.Error() != "metadata file not found" {
+		log.Printf("WARNING: error in metadata for contracts: %s\n", fileCmdName)
+		log.Println(err)
+		metadataErr = err
+	} else if err == nil {
+		isMetadata = true
+		// Set command description
+		fileCmd.Short = metadata.Description
+		argContents, flagContents, err = utils.ConfigureArgumentsAndFlags(fileCmd, metadata, "")
+		if err != nil {
+			metadataErr = flagsError(fileCmdName, err)
+		} else if inputSchema, err = utils.CompileInputSchema(metadata); err != nil {
+			log.Printf("WARNING: error in the schema of contracts: %s\n", fileCmdName)
 			log.Println(err)
-		} else if err == nil {
+			metadataErr = fmt.Errorf("invalid schema: %w", err)
+		}
+	} else {
+		// Without introspection the contract has no flags for its inputs, it still runs with
+		// its side files
+		introspectionData, _ := utils.IntrospectContract(file.Content)
+		argContents, flagContents, err = utils.ConfigureArgumentsAndFlags(fileCmd, metadata, introspectionData)
+		if err != nil {
+			metadataErr = flagsError(fileCmdName, err)
+		}
+		if introspectionData != "" && introspectionData != "{}" {
 			isMetadata = true
-			// Set command description
-			fileCmd.Short = metadata.Description
-			argContents, flagContents, err = utils.ConfigureArgumentsAndFlags(fileCmd, metadata, "")
-			if err != nil {
-				log.Printf("Failed to set arguments or flags: %v\n", err)
-				os.Exit(1)
-			}
-			fileCmd.PreRunE = func(cmd *cobra.Command, _ []string) error {
-				return utils.ValidateFlags(cmd, flagContents, argContents, &input)
-			}
-		} else {
-			introspectionData, err := slangroom.Introspect(file.Content)
-			if err == nil {
-				introspectionData = utils.CleanIntrospection(file.Content, introspectionData)
-			} else {
-				introspectionData = ""
-			}
-			argContents, flagContents, err = utils.ConfigureArgumentsAndFlags(fileCmd, metadata, introspectionData)
-			if err != nil {
-				log.Printf("Failed to set arguments or flags: %v\n", err)
-				os.Exit(1)
-			}
-			if introspectionData != "" && introspectionData != "{}" {
-				isMetadata = true
-			}
 		}
+	}
 
-		fileCmd.PreRunE = func(cmd *cobra.Command, _ []string) error {
-			return utils.ValidateFlags(cmd, flagContents, argContents, &input)
+	fileCmd.PreRunE = func(cmd *cobra.Command, _ []string) error {
+		if metadataErr != nil {
+			return &exitError{code: ExitMetadata, err: metadataErr}
 		}
-		// Set the command's run function
-		fileCmd.Run = func(_ *cobra.Command, args []string) {
-			runFileCommand(file, args, metadata, argContents, isMetadata, &input)
+		if err := utils.ValidateFlags(cmd, flagContents, argContents, &input); err != nil {
+			return &exitError{code: ExitInputValidation, err: err}
 		}
-
-		// Add the file command to its directory's command
-		parentCmd.AddCommand(fileCmd)
-	})
-
-	if err != nil {
-		log.Println("Error adding embedded file commands:", err)
+		return nil
+	}
+	// Set the command's run function
+	fileCmd.Run = func(cmd *cobra.Command, args []string) {
+		runFileCommand(cmd, file, folder, args, metadata, inputSchema, argContents, flagContents, isMetadata, &input)
 	}
+	return fileCmd
+}
+
+// flagsError logs the error found setting the arguments and flags of a contract, like a reserved
+// flag name, and returns it to be reported when the contract is used: the other contracts still work
+func flagsError(fileCmdName string, err error) error {
+	log.Printf("WARNING: error in arguments or flags for contracts: %s\n", fileCmdName)
+	log.Println(err)
+	return fmt.Errorf("failed to set arguments or flags: %w", err)
 }
 
 // runCmd is a command that executes a specific slangroom file from a given folder.
@@ -185,18 +392,35 @@ var runCmd = &cobra.Command{
 	Use:   filepath.Base(os.Args[0]) + " [folder]",
 	Short: "Execute a specific slangroom file in a dynamically specified folder or in the embedded folder contracts",
 	Args:  cobra.ArbitraryArgs,
+	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		// A --bundle after the command is parsed when the contracts are already loaded
+		if bundle != bundleFlag {
+			return fmt.Errorf("--bundle must come before the command")
+		}
+		if err := inputFlags.Merge.Validate(); err != nil {
+			return err
+		}
+		if profile == "" {
+			profile = os.Getenv(utils.ProfileEnv)
+		}
+		if err := utils.ValidateProfile(profile); err != nil {
+			return err
+		}
+		return utils.ValidateOutputFormat(outputOptions.Format)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		if daemon {
 			if len(args) == 0 {
 				httpInput := httpserver.HTTPInput{
 					BinaryName:     filepath.Base(os.Args[0]),
-					EmbeddedFolder: &contracts,
+					EmbeddedFolder: contracts,
 					EmbeddedPath:   "contracts",
+					Manifest:       manifest,
 					Port:           port,
 				}
 				if err := httpserver.StartHTTPServer(httpInput); err != nil {
 					log.Printf("Failed to start HTTP server: %v\n", err)
-					os.Exit(1)
+					os.Exit(ExitFailure)
 				}
 				return
 			}
@@ -211,7 +435,7 @@ var runCmd = &cobra.Command{
 				}
 				if err := httpserver.StartHTTPServer(httpInput); err != nil {
 					log.Printf("Failed to start HTTP server: %v\n", err)
-					os.Exit(1)
+					os.Exit(ExitFailure)
 				}
 				return
 			}
@@ -221,10 +445,8 @@ var runCmd = &cobra.Command{
 			log.Println("Error: folder or  argument is required")
 			if err := cmd.Help(); err != nil {
 				log.Printf("Failed to start the program: %v\n", err)
-				os.Exit(1)
 			}
-
-			return
+			os.Exit(ExitUsage)
 		}
 		folder := args[0]
 		filePath := filepath.Join(args[1:]...)
@@ -239,12 +461,24 @@ var runCmd = &cobra.Command{
 
 			if relativeFilePath == filePath {
 				found = true
+				sideInput, sources, err := utils.LoadSideFiles(os.DirFS(folder), folder, file.Dir, filename, profile)
+				if err != nil {
+					fail(ExitInputValidation, "Failed to load data from JSON file: %v\n", err)
+				}
+				input = sideInput
 				input.Contract = file.Content
-				err := utils.LoadAdditionalData(filepath.Join(folder, file.Dir), filename, &input)
-
+				sources.Contract = file.Path
+				side := utils.InputLayer{Input: input, Sources: sources}
+				flagsLayer, err := utils.ApplyInputFlags(&input, inputFlags, os.Stdin, &sources)
 				if err != nil {
-					log.Printf("Failed to load data from JSON file: %v\n", err)
-					os.Exit(1)
+					fail(ExitInputValidation, "Error: %v\n", err)
+				}
+				printMergeDiagnostics(side, flagsLayer)
+
+				if dryRun && !daemon {
+					utils.DataSources(cmd, nil, nil, nil, input.Data, "", &sources)
+					printInspection(input, sources, nil)
+					return
 				}
 
 				// Start HTTP server if daemon flag is set
@@ -256,50 +490,100 @@ var runCmd = &cobra.Command{
 					}
 					if err := httpserver.StartHTTPServer(httpInput); err != nil {
 						log.Printf("Failed to start HTTP server: %v\n", err)
-						os.Exit(1)
+						os.Exit(ExitFailure)
 					}
 					return
 				}
 
-				// Execute the slangroom file
-				res, err := slangroom.Exec(input)
-				if err != nil {
-					log.Println("Error:", err)
-					log.Println(res.Logs)
-				} else {
-					fmt.Println(res.Output)
-				}
+				// Execute the slangroom file, the keys never reach the logs
+				execute(input, utils.NewInputRedactor(input, nil))
 			}
 		})
 
 		if err != nil {
-			log.Println("Error:", err)
-			return
+			fail(ExitNotFound, "Error: %v\n", err)
 		}
 
 		if !found {
-			log.Printf("File %s not found in folder %s\n", filePath, folder)
+			fail(ExitNotFound, "File %s not found in folder %s\n", filePath, folder)
 		}
 	},
 }
 
-func runFileCommand(file fouter.SlangFile, args []string, metadata *utils.CommandMetadata, argContents map[string]interface{}, isMetadata bool, input *slangroom.SlangroomInput) {
+// runFileCommand executes the contract of a command created by newContractCommand, the folder is
+// empty for the embedded contracts. The input is validated against the compiled input schema of
+// the metadata, when it has one.
+func runFileCommand(cmd *cobra.Command, file fouter.SlangFile, folder string, args []string, metadata *utils.CommandMetadata, inputSchema *jsschema.Schema, argContents map[string]interface{}, flagContents map[string]utils.FlagData, isMetadata bool, input *slangroom.SlangroomInput) {
 	filename := strings.TrimSuffix(file.FileName, filepath.Ext(file.FileName))
-	err := utils.LoadAdditionalData(file.Dir, filename, input)
+	// The data read by ValidateFlags from the file flags is merged over the side files and the input flags
+	flagData := input.Data
+	// The side files of the embedded contracts are embedded along with them
+	var sideFS fs.FS = contracts
+	if folder != "" {
+		sideFS = os.DirFS(folder)
+	}
+	if isMetadata && metadata != nil {
+		// The arguments are set first, the side files can refer to them with ${arg:name}
+		if err := utils.SetArgumentValues(metadata, args, argContents); err != nil {
+			fail(ExitInputValidation, "Error: %v\n", err)
+		}
+	}
+	side, sources, err := utils.LoadSideFiles(sideFS, folder, file.Dir, filename, profile)
+	if err != nil {
+		fail(ExitInputValidation, "Failed to load data from JSON file: %v\n", err)
+	}
+	// The placeholders are replaced only when the metadata opts in, so a literal ${ stays as it is
+	interpolate := metadata != nil && metadata.Interpolate
+	var interpolator utils.Interpolator
+	if interpolate {
+		interpolator = utils.Interpolator{FS: sideFS, Dir: file.Dir, Args: argContents, AllowEnv: metadata.InterpolateEnv}
+		if err := interpolator.InterpolateInput(&side, sources); err != nil {
+			fail(ExitInputValidation, "Error: %v\n", err)
+		}
+	}
+	// The secrets are read from the disk also for the embedded contracts, relative to the working
+	// directory, so that they are never embedded. Only the embedded contracts are trusted to run
+	// commands and to read outside of their folder, unless the user opts in.
+	redactor := &utils.Redactor{}
+	secretOptions := utils.SecretOptions{Dir: ".", Trusted: trustSecrets || (folder == "" && !fromBundle), Redactor: redactor}
+	if folder != "" {
+		secretOptions.Dir = filepath.Join(folder, file.Dir)
+	}
+	if err := utils.LoadSecrets(utils.SecretRefs(metadata), secretOptions, &side, &sources); err != nil {
+		fail(ExitInputValidation, "Error: %v\n", err)
+	}
+	*input = side
+	input.Contract = file.Content
+	sources.Contract = file.Path
+	if folder == "" {
+		sources.Contract = "embedded " + file.Path
+	}
+	layers := []utils.InputLayer{{Input: *input, Sources: sources}}
+	flagsLayer, err := utils.ApplyInputFlags(input, inputFlags, os.Stdin, &sources)
 	if err != nil {
-		log.Printf("Failed to load data from JSON file: %v\n", err)
-		os.Exit(1)
+		fail(ExitInputValidation, "Error: %v\n", err)
+	}
+	layers = append(layers, flagsLayer, utils.InputLayer{
+		Input:   slangroom.SlangroomInput{Data: flagData},
+		Sources: utils.InputSources{DataFile: "file flags"},
+	})
+	baseData := input.Data
+	if input.Data, err = utils.Merge(input.Data, flagData, inputFlags.Merge); err != nil {
+		fail(ExitInputValidation, "Error: %v\n", err)
 	}
 	if isMetadata {
-		for key, value := range metadata.Environment {
-			if err := os.Setenv(key, value); err != nil {
-				log.Println("Failed to set environment variable:", key)
-				os.Exit(1)
+		if metadata != nil {
+			environment := metadata.Environment
+			if interpolate {
+				if environment, err = interpolator.InterpolateEnvironment(environment); err != nil {
+					fail(ExitInputValidation, "Error: %v\n", err)
+				}
 			}
-		}
-		for i, arg := range args {
-			if i < len(metadata.Arguments) {
-				argContents[utils.NormalizeArgumentName(metadata.Arguments[i].Name)] = arg
+			for key, value := range environment {
+				if err := os.Setenv(key, value); err != nil {
+					log.Println("Failed to set environment variable:", key)
+					os.Exit(ExitFailure)
+				}
 			}
 		}
 		// Convert argContents to JSON if needed
@@ -309,36 +593,124 @@ func runFileCommand(file fouter.SlangFile, args []string, metadata *utils.Comman
 			return
 		}
 		if input.Data != "" {
-			if input.Data, err = utils.MergeJSON(input.Data, string(jsonData)); err != nil {
-				log.Println("Error encoding arguments to JSON:", err)
-				os.Exit(1)
+			if input.Data, err = utils.Merge(input.Data, string(jsonData), inputFlags.Merge); err != nil {
+				fail(ExitInputValidation, "Error encoding arguments to JSON: %v\n", err)
 			}
 		} else {
 			input.Data = string(jsonData)
 		}
+		layers = append(layers, utils.InputLayer{
+			Input:   slangroom.SlangroomInput{Data: string(jsonData)},
+			Sources: utils.InputSources{DataFile: "arguments and flags"},
+		})
 	}
+	printMergeDiagnostics(layers...)
+	// The server validates each request against the same schema
+	if !daemon {
+		if err := utils.ValidateInput(inputSchema, input.Data, metadata, args); err != nil {
+			fail(ExitInputValidation, "Error: %v\n", err)
+		}
+	}
+	// The keys and the secret data never reach the logs
+	redactor.AddInput(*input, utils.SecretPaths(metadata))
 	// Start HTTP server if daemon flag is set
 	if daemon {
 		httpInput := httpserver.HTTPInput{
 			BinaryName:     filepath.Base(os.Args[0]),
-			EmbeddedFolder: &contracts,
+			EmbeddedFolder: contracts,
 			EmbeddedPath:   "contracts",
+			Manifest:       manifest,
 			FileName:       filename,
 			Port:           port,
 		}
+		if folder != "" {
+			httpInput = httpserver.HTTPInput{
+				BinaryName: filepath.Base(os.Args[0]),
+				Path:       file.Path,
+				Port:       port,
+			}
+		}
 		if err := httpserver.StartHTTPServer(httpInput); err != nil {
 			log.Printf("Failed to start HTTP server: %v\n", err)
-			os.Exit(1)
+			os.Exit(ExitFailure)
 		}
 		return
 	}
 
+	if dryRun {
+		utils.DataSources(cmd, metadata, flagContents, args, baseData, flagData, &sources)
+		printInspection(*input, sources, utils.SecretPaths(metadata))
+		return
+	}
+
 	// Execute the slangroom file
-	res, err := slangroom.Exec(*input)
+	execute(*input, redactor)
+}
+
+// execute runs the contract and prints its output, on failure the zenroom logs and the error are
+// written to the standard error as JSON lines, the error has the heap and the trace with --verbose,
+// and the process exits with the code of the failure. The secrets of the redactor never reach the
+// logs.
+func execute(input slangroom.SlangroomInput, redactor *utils.Redactor) {
+	res, err := utils.ExecContract(input, execTimeout)
+	if err != nil {
+		logs := redactor.Redact(res.Logs)
+		execErr := utils.ParseExecutionError(input.Contract, logs, err)
+		if logErr := utils.WriteLogs(os.Stderr, logs); logErr != nil {
+			log.Println("Failed to write logs:", logErr)
+		}
+		line, jsonErr := utils.ExecutionErrorLine(execErr, verbose)
+		if jsonErr != nil {
+			log.Println("Error:", redactor.Redact(execErr.Error()))
+		} else {
+			fmt.Fprint(os.Stderr, redactor.Redact(string(line)))
+		}
+		os.Exit(execExitCode(execErr))
+	}
+	printResult(res.Output)
+}
+
+// printMergeDiagnostics prints, with --merge-diagnostics, which layer of the input won each value
+func printMergeDiagnostics(layers ...utils.InputLayer) {
+	if !mergeDiagnostics {
+		return
+	}
+	diagnostics, err := utils.MergeDiagnostics(layers, inputFlags.Merge)
 	if err != nil {
 		log.Println("Error:", err)
-		log.Println(res.Logs)
-	} else {
-		fmt.Println(res.Output)
+		return
+	}
+	document, err := utils.ToJSON(diagnostics)
+	if err != nil {
+		log.Println("Error:", err)
+		return
+	}
+	log.Println(string(document))
+}
+
+// printInspection prints the input that would be sent to slangroom along with its sources
+func printInspection(input slangroom.SlangroomInput, sources utils.InputSources, secretPaths []string) {
+	inspection, err := utils.Inspect(input, sources, secretPaths)
+	if err != nil {
+		log.Println("Error:", err)
+		os.Exit(ExitFailure)
+	}
+	fmt.Println(string(inspection))
+}
+
+// printResult prints the output of a contract, or writes it to the out-file, as requested by the output flags
+func printResult(output string) {
+	result, err := utils.FormatOutput(output, outputOptions)
+	if err != nil {
+		log.Println("Error:", err)
+		os.Exit(ExitFailure)
+	}
+	if outFile != "" {
+		if err := utils.WriteFileAtomic(outFile, append(result, '\n'), 0600); err != nil {
+			log.Println("Error:", err)
+			os.Exit(ExitFailure)
+		}
+		return
 	}
+	fmt.Println(string(result))
 }
@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"archive/zip"
 	"bytes"
+	"encoding/json"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -78,6 +80,35 @@ func TestListCommand(t *testing.T) {
 			t.Errorf("Expected output to contain 'Found file: file2', got %v", output)
 		}
 	})
+
+	// Test the machine readable output
+	t.Run("List Embedded Files as JSON", func(t *testing.T) {
+		cmd := exec.Command("go", "run", "../main.go", "list", "--format", "json")
+
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		err := cmd.Run()
+		if err != nil {
+			t.Fatalf("Command execution failed: %v", err)
+		}
+
+		var contracts []map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &contracts); err != nil {
+			t.Fatalf("Expected a JSON list, got %v", out.String())
+		}
+		found := false
+		for _, contract := range contracts {
+			if contract["path"] == "test/param" {
+				found = true
+				if contract["embedded"] != true || contract["source"] != "embedded" || contract["description"] == "" || contract["hash"] == "" {
+					t.Errorf("Unexpected details for test/param: %v", contract)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected test/param in the list, got %v", out.String())
+		}
+	})
 }
 
 func TestRunCommand(t *testing.T) {
@@ -122,9 +153,559 @@ Then print the string 'Hello'`
 			t.Errorf("Expected output to contain 'Hello from embedded!', got %v", output)
 		}
 	})
+
+	// Subtest for running a contract of a folder with the arguments and flags of its metadata
+	t.Run("Folder with metadata", func(t *testing.T) {
+		run := func(args ...string) string {
+			cmd := exec.Command("go", append([]string{"run", "../main.go"}, args...)...)
+			var out bytes.Buffer
+			cmd.Stdout = &out
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("Command execution failed: %v", err)
+			}
+			return out.String()
+		}
+		folder := run("run", "../contracts", "test/param", "alice", "--drink", "small", "--name", "bob")
+		embedded := run("test", "param", "alice", "--drink", "small", "--name", "bob")
+		if folder != embedded {
+			t.Errorf("Expected the same output as the embedded contract %s, got %s", embedded, folder)
+		}
+		if !contains(folder, `"username":"alice"`) || !contains(folder, `"drink":"small"`) {
+			t.Errorf("Expected the arguments and flags in the output, got %s", folder)
+		}
+	})
+
+	// Subtest for a folder with spaces in its name and the root flags before the command
+	t.Run("Folder with spaces", func(t *testing.T) {
+		folder := filepath.Join(t.TempDir(), "my contracts")
+		if err := os.Mkdir(folder, 0755); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+		files := map[string]string{
+			"hello.slang":         "Given I have a 'string' named 'name'\nThen print the data\n",
+			"hello.metadata.json": `{"options": [{"name": "--name <name>"}]}`,
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(folder, name), []byte(content), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+		for _, args := range [][]string{
+			{"--dry-run", "run", folder, "hello", "--name", "bob"},
+			{"--merge", "shallow", "inspect", folder, "hello", "--name", "bob"},
+		} {
+			cmd := exec.Command("go", append([]string{"run", "../main.go"}, args...)...)
+			var out, stderr bytes.Buffer
+			cmd.Stdout = &out
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("Command %q failed: %v %s", args, err, stderr.String())
+			}
+			if !contains(out.String(), `"name": "bob"`) {
+				t.Errorf("Expected the flag in the input of %q, got %v", args, out.String())
+			}
+		}
+	})
 }
 
 // Helper function to check if a substring is in a string
+func TestInspectCommand(t *testing.T) {
+	for name, args := range map[string][]string{
+		"Inspect": {"inspect", "test/param", "alice", "--drink", "small"},
+		"Dry run": {"test", "param", "alice", "--drink", "small", "--dry-run"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cmd := exec.Command("go", append([]string{"run", "../main.go"}, args...)...)
+
+			var out bytes.Buffer
+			cmd.Stdout = &out
+			err := cmd.Run()
+			if err != nil {
+				t.Fatalf("Command execution failed: %v", err)
+			}
+			output := out.String()
+			for _, expected := range []string{
+				`"source": "embedded contracts/test/param.slang"`,
+				`"username": "argument <username>"`,
+				`"drink": "flag --drink"`,
+				`"timeout": "default of --timeout"`,
+				`"password": "[REDACTED]"`,
+				`"username": "alice"`,
+			} {
+				if !contains(output, expected) {
+					t.Errorf("Expected output to contain %s, got %v", expected, output)
+				}
+			}
+		})
+	}
+}
+
+func TestMetadataValidateEmbedded(t *testing.T) {
+	cmd := exec.Command("go", "run", "../main.go", "metadata", "validate")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Expected the embedded contracts to pass the validation: %v\n%s", err, out.String())
+	}
+	if !contains(out.String(), "All metadata files are valid") {
+		t.Errorf("Expected all metadata files to be valid, got %v", out.String())
+	}
+}
+
+func TestShadowedFlags(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"shadow.slang":         "Given I have a 'string' named 'port'\nThen print the data\n",
+		"shadow.metadata.json": `{"options": [{"name": "-p, --port <number>"}]}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// The --port flag of the contract shadows the one of the daemon mode
+	cmd := exec.Command("go", "run", "../main.go", "run", tempDir, "shadow", "--port", "1234", "--dry-run")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	if !contains(out.String(), `"port": "1234"`) {
+		t.Errorf("Expected the port of the contract, got %v", out.String())
+	}
+
+	cmd = exec.Command("go", "run", "../main.go", "metadata", "validate", tempDir)
+	out.Reset()
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Expected the shadowing flag to be valid, got %v: %v", err, out.String())
+	}
+}
+
+func TestInputFlags(t *testing.T) {
+	keys := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(keys, []byte(`{"keyring": {"eddsa": "secret"}}`), 0600); err != nil {
+		t.Fatalf("Failed to write keys: %v", err)
+	}
+	cmd := exec.Command("go", "run", "../main.go", "test", "param", "alice", "--data", `{"name": "bob"}`, "--keys", "@"+keys, "--dry-run")
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	output := out.String()
+	for _, expected := range []string{
+		`"name": "flag --data"`,
+		`"name": "bob"`,
+		`"source": "file ` + keys + ` (--keys)"`,
+		`"eddsa": "[REDACTED]"`,
+	} {
+		if !contains(output, expected) {
+			t.Errorf("Expected output to contain %s, got %v", expected, output)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"hello.slang":                   "Given I have a 'string' named 'name'\nThen print the data\n",
+		"hello.data.json":               `{"name": "dev", "url": "http://localhost"}`,
+		"hello.data.staging.json":       `{"name": "staging"}`,
+		"profiles/prod/hello.data.json": `{"name": "prod"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      string
+		expected string
+	}{
+		{name: "No profile", args: []string{"run", tempDir, "hello"}, expected: `"name":"dev"`},
+		{name: "Profile flag", args: []string{"run", tempDir, "hello", "--profile", "staging"}, expected: `"name":"staging"`},
+		{name: "Profile directory", args: []string{tempDir, "hello", "--profile", "prod"}, expected: `"name":"prod"`},
+		{name: "Profile environment variable", args: []string{"run", tempDir, "hello"}, env: "staging", expected: `"name":"staging"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command("go", append([]string{"run", "../main.go"}, tt.args...)...)
+			cmd.Env = append(os.Environ(), "TWINROOM_PROFILE="+tt.env)
+			var out bytes.Buffer
+			cmd.Stdout = &out
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("Command execution failed: %v", err)
+			}
+			if !contains(out.String(), tt.expected) || !contains(out.String(), `"url":"http://localhost"`) {
+				t.Errorf("Expected output to contain %s and the base url, got %v", tt.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestSideFileFormats(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"hello.slang":         "Given I have a 'string' named 'name'\nGiven I have a 'string' named 'url'\nThen print the data\n",
+		"hello.metadata.yaml": "description: Say hello\noptions:\n  - name: -n, --name <string>\n    description: Name to greet\n",
+		"hello.data.yaml":     "name: dev\nurl: http://localhost\n",
+		"mixed.slang":         "Given I have a 'string' named 'name'\nThen print the data\n",
+		"mixed.data.json5":    "{name: 'dev', // a comment\n}",
+		"mixed.metadata.json": `{"description": "Mixed formats"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", "../main.go", "run", tempDir, "hello", "--name", "yaml")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	if !contains(out.String(), `"name":"yaml"`) || !contains(out.String(), `"url":"http://localhost"`) {
+		t.Errorf("Expected the YAML data with the flag of the YAML metadata, got %v", out.String())
+	}
+
+	cmd = exec.Command("go", "run", "../main.go", "run", tempDir, "mixed")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("Expected an error for the mixed formats")
+	}
+	if !contains(stderr.String(), "mixes formats") {
+		t.Errorf("Expected a format conflict error, got %v", stderr.String())
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"hello.slang":          "Given I have a 'string' named 'greeting'\nThen print the data\n",
+		"hello.metadata.json":  `{"arguments": [{"name": "<name>"}, {"name": "[title]"}], "interpolate": true}`,
+		"hello.data.json":      `{"greeting": "hi ${arg:title:-dear} ${arg:name}", "home": "${env:TWINROOM_TEST_HOME}", "token": "${file:token.txt}", "level": "${env:TWINROOM_TEST_MISSING:-info}"}`,
+		"bye.slang":            "Given I have a 'string' named 'farewell'\nThen print the data\n",
+		"bye.metadata.json":    `{"arguments": [{"name": "[name]"}], "interpolate": true}`,
+		"bye.data.json":        `{"farewell": "bye ${arg:name}"}`,
+		"token.txt":            "s3cr3t\n",
+		"broken.slang":         "Given I have a 'string' named 'home'\nThen print the data\n",
+		"broken.data.json":     `{"home": "${env:TWINROOM_TEST_MISSING}"}`,
+		"broken.metadata.json": `{"interpolate": true}`,
+		"literal.slang":        "Given I have a 'string' named 'template'\nThen print the data\n",
+		"literal.data.json":    `{"template": "Dear ${name}"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", "../main.go", "run", tempDir, "hello", "alice")
+	cmd.Env = append(os.Environ(), "TWINROOM_TEST_HOME=/home/alice")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	for _, expected := range []string{`"greeting":"hi dear alice"`, `"home":"/home/alice"`, `"token":"s3cr3t"`, `"level":"info"`} {
+		if !contains(out.String(), expected) {
+			t.Errorf("Expected output to contain %s, got %v", expected, out.String())
+		}
+	}
+
+	cmd = exec.Command("go", "run", "../main.go", "run", tempDir, "hello", "alice", "miss")
+	cmd.Env = append(os.Environ(), "TWINROOM_TEST_HOME=/home/alice")
+	out.Reset()
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	if !contains(out.String(), `"greeting":"hi miss alice"`) {
+		t.Errorf("Expected the optional argument in the output, got %v", out.String())
+	}
+
+	// An optional argument that is not given has no value
+	cmd = exec.Command("go", "run", "../main.go", "run", tempDir, "bye")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("Expected an error for the omitted argument")
+	}
+	if !contains(stderr.String(), "argument name is not set") {
+		t.Errorf("Expected a missing argument error, got %v", stderr.String())
+	}
+
+	cmd = exec.Command("go", "run", "../main.go", "run", tempDir, "broken")
+	stderr.Reset()
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("Expected an error for the missing environment variable")
+	}
+	if !contains(stderr.String(), "environment variable TWINROOM_TEST_MISSING is not set") {
+		t.Errorf("Expected a missing variable error, got %v", stderr.String())
+	}
+
+	// Without interpolate in the metadata the side files are left as they are
+	cmd = exec.Command("go", "run", "../main.go", "run", tempDir, "literal")
+	out.Reset()
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	if !contains(out.String(), `"template":"Dear ${name}"`) {
+		t.Errorf("Expected the literal placeholder in the output, got %v", out.String())
+	}
+}
+
+func TestSchemaInput(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"order.slang":         "Given I have a 'string' named 'drink'\nGiven I have a 'number' named 'count'\nThen print the data\n",
+		"order.metadata.json": `{"schema": {"type": "object", "required": ["drink"], "properties": {"drink": {"type": "string", "enum": ["small", "large"]}, "count": {"type": "integer", "minimum": 1, "default": 1}}}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// The flags come from the properties of the schema
+	cmd := exec.Command("go", "run", "../main.go", "run", tempDir, "order", "--drink", "large", "--count", "2")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	for _, expected := range []string{`"drink":"large"`, `"count":2`} {
+		if !contains(out.String(), expected) {
+			t.Errorf("Expected output to contain %s, got %v", expected, out.String())
+		}
+	}
+
+	// The input is validated against the schema before the execution
+	for _, args := range [][]string{{"--drink", "large", "--count", "0"}, {"--count", "2"}} {
+		cmd = exec.Command("go", append([]string{"run", "../main.go", "run", tempDir, "order"}, args...)...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err == nil {
+			t.Fatalf("Expected an error for %v", args)
+		}
+		if !contains(stderr.String(), "does not match the schema") {
+			t.Errorf("Expected a schema error for %v, got %v", args, stderr.String())
+		}
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"hello.slang":         "Given I have a 'string' named 'eddsa' in 'keyring'\nThen print the data\n",
+		"hello.metadata.json": `{"secrets": [{"provider": "env", "env": "TWINROOM_TEST_EDDSA", "key": "keyring.eddsa"}]}`,
+		"hello.keys.json":     `{"keyring": {"ecdh": "plaintext"}}`,
+		"pin.slang":           "Given I have a 'string' named 'pin_code'\nThen print the data\n",
+		"pin.metadata.json":   `{"options": [{"name": "--pin_code <pin>", "secret": true}]}`,
+		"vault.slang":         "Given I have a 'string' named 'eddsa' in 'keyring'\nThen print the data\n",
+		"vault.metadata.json": `{"keys_command": ["echo", "{\"keyring\": {\"eddsa\": \"COMMAND-SECRET-KEY\"}}"]}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", "../main.go", "run", tempDir, "hello", "--dry-run")
+	cmd.Env = append(os.Environ(), "TWINROOM_TEST_EDDSA=EDDSA-SECRET-KEY")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	if contains(out.String(), "EDDSA-SECRET-KEY") {
+		t.Errorf("Expected the secret to be redacted, got %v", out.String())
+	}
+	if !contains(out.String(), "secret env TWINROOM_TEST_EDDSA (keyring.eddsa)") {
+		t.Errorf("Expected the secret among the sources of the keys, got %v", out.String())
+	}
+
+	cmd = exec.Command("go", "run", "../main.go", "run", tempDir, "hello")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("Expected an error for the missing secret")
+	}
+	if !contains(stderr.String(), "environment variable TWINROOM_TEST_EDDSA is not set") {
+		t.Errorf("Expected a missing secret error, got %v", stderr.String())
+	}
+
+	cmd = exec.Command("go", "run", "../main.go", "run", tempDir, "pin", "--pin_code", "98765", "--dry-run")
+	out.Reset()
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	if contains(out.String(), "98765") || !contains(out.String(), `"pin_code": "[REDACTED]"`) {
+		t.Errorf("Expected the secret option to be redacted, got %v", out.String())
+	}
+
+	// The contracts of a folder run the secret commands only with --trust-secrets
+	cmd = exec.Command("go", "run", "../main.go", "run", tempDir, "vault", "--dry-run")
+	stderr.Reset()
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("Expected an error for the command of a folder contract")
+	}
+	if !contains(stderr.String(), "--trust-secrets") {
+		t.Errorf("Expected the error to suggest --trust-secrets, got %v", stderr.String())
+	}
+	cmd = exec.Command("go", "run", "../main.go", "run", tempDir, "vault", "--dry-run", "--trust-secrets")
+	out.Reset()
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	if !contains(out.String(), "secret command echo") {
+		t.Errorf("Expected the command among the sources of the keys, got %v", out.String())
+	}
+}
+
+func TestBundle(t *testing.T) {
+	bundlePath := filepath.Join(t.TempDir(), "contracts.zip")
+	files := map[string]string{
+		"greet/hello.slang":         "Given I have a 'string' named 'name'\nGiven I have a 'string' named 'from'\nThen print the data\n",
+		"greet/hello.metadata.json": `{"description": "Say hello from the bundle", "options": [{"name": "--name <name>"}]}`,
+		"greet/hello.data.json":     `{"from": "bundle"}`,
+	}
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bundlePath, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("go", "run", "../main.go", "--bundle", bundlePath, "greet", "hello", "--name", "dev", "--dry-run")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	if !contains(out.String(), `"name": "dev"`) || !contains(out.String(), `"from": "contracts/greet/hello.data.json"`) {
+		t.Errorf("Expected the flag and the side file of the bundle, got %v", out.String())
+	}
+
+	cmd = exec.Command("go", "run", "../main.go", "list")
+	cmd.Env = append(os.Environ(), "TWINROOM_BUNDLE="+bundlePath)
+	out.Reset()
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	if !contains(out.String(), "Found file: greet/hello") || contains(out.String(), "test/hello") {
+		t.Errorf("Expected only the contracts of the bundle, got %v", out.String())
+	}
+
+	// The contracts of the bundle are not embedded, the --output of the contracts is left alone
+	cmd = exec.Command("go", "run", "../main.go", "--bundle", bundlePath, "list", "--format", "json", "--output", "yaml")
+	out.Reset()
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command execution failed: %v", err)
+	}
+	if !contains(out.String(), `"source": "bundle"`) || !contains(out.String(), `"embedded": false`) {
+		t.Errorf("Expected the contracts of the bundle to be listed as such, got %v", out.String())
+	}
+
+	cmd = exec.Command("go", "run", "../main.go", "--bundle", bundlePath+".missing", "list")
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected an error for a missing bundle")
+	}
+
+	// The bundle is not taken from the value of another flag nor from the flags of the command
+	for _, tt := range []struct {
+		args     []string
+		expected string
+	}{
+		{args: []string{"--bundle"}, expected: "flag needs an argument: --bundle"},
+		{args: []string{"--data", "--bundle", bundlePath, "greet", "hello"}, expected: "not found in folder " + bundlePath},
+		{args: []string{"list", "--bundle", bundlePath}, expected: "--bundle must come before the command"},
+	} {
+		cmd = exec.Command("go", append([]string{"run", "../main.go"}, tt.args...)...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err == nil || !contains(stderr.String(), tt.expected) {
+			t.Errorf("Expected %q for %v, got %v: %s", tt.expected, tt.args, err, stderr.String())
+		}
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	// go run always exits with 1, so build the binary to check the exit codes
+	binary := filepath.Join(t.TempDir(), "twinroom")
+	build := exec.Command("go", "build", "-o", binary, "..")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build: %v\n%s", err, out)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{name: "Success", args: []string{"test", "hello"}, code: ExitOK},
+		{name: "Unknown flag", args: []string{"test", "hello", "--bogus"}, code: ExitUsage},
+		{name: "Invalid output", args: []string{"test", "hello", "--output", "xml"}, code: ExitUsage},
+		{name: "Unknown contract", args: []string{"test", "missing"}, code: ExitNotFound},
+		{name: "Contract not in folder", args: []string{"../contracts", "test/missing"}, code: ExitNotFound},
+		{name: "Missing argument", args: []string{"test", "param"}, code: ExitInputValidation},
+		{name: "Invalid choice", args: []string{"test", "param", "alice", "--drink", "huge"}, code: ExitInputValidation},
+		{name: "Broken contract", args: []string{"test", "broken"}, code: ExitExecution},
+		{name: "Run contract not in folder", args: []string{"run", "../contracts", "test/missing"}, code: ExitNotFound},
+		{name: "Run missing argument", args: []string{"run", "../contracts", "test/param"}, code: ExitInputValidation},
+		{name: "Invalid data flag", args: []string{"test", "hello", "--data", "{"}, code: ExitInputValidation},
+		{name: "Invalid merge", args: []string{"test", "hello", "--merge", "overwrite"}, code: ExitUsage},
+		{name: "Invalid merge arrays", args: []string{"test", "hello", "--merge-arrays", "zip"}, code: ExitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(binary, tt.args...)
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+			code := 0
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("Command execution failed: %v", err)
+			}
+			if code != tt.code {
+				t.Errorf("Expected exit code %d, got %d: %s", tt.code, code, stderr.String())
+			}
+		})
+	}
+}
+
 func contains(str, substr string) bool {
 	return len(str) >= len(substr) && strings.Contains(str, substr)
 }
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"github.com/forkbombeu/twinroom/cmd/utils"
+)
+
+// Exit codes of the process, documented in the README
+const (
+	ExitOK               = 0
+	ExitFailure          = 1   // any other failure, like the HTTP server not starting
+	ExitUsage            = 2   // unknown commands, flags or wrong number of arguments
+	ExitNotFound         = 3   // the contract or the folder does not exist
+	ExitMetadata         = 4   // the metadata of the contract is invalid
+	ExitInputValidation  = 5   // missing or invalid arguments, flags or data files
+	ExitExecution        = 6   // the contract failed
+	ExitTimeout          = 124 // the contract did not end within --exec-timeout
+	ExitSlangroomMissing = 127 // slangroom-exec is not installed
+)
+
+// exitError is an error that terminates the process with a specific exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// exitCode returns the exit code of an error returned by the execution of the root command
+func exitCode(err error) int {
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	var missingErr *utils.MissingInputError
+	if errors.As(err, &missingErr) {
+		return ExitInputValidation
+	}
+	return ExitUsage
+}
+
+// fail logs the message and terminates the process with the exit code
+func fail(code int, format string, args ...interface{}) {
+	log.Printf(format, args...)
+	os.Exit(code)
+}
+
+// execExitCode returns the exit code of a failed contract execution
+func execExitCode(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrTimeout):
+		return ExitTimeout
+	case errors.Is(err, utils.ErrSlangroomMissing):
+		return ExitSlangroomMissing
+	default:
+		return ExitExecution
+	}
+}
@@ -2,24 +2,36 @@ package httpserver
 
 import (
 	"context"
-	"embed"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"net"
 	"net/http"
+	"os"
 	"time"
+
+	"github.com/forkbombeu/twinroom/cmd/utils"
 )
 
 // define the input needed to start the server
 type HTTPInput struct {
 	BinaryName     string
-	EmbeddedFolder *embed.FS
+	EmbeddedFolder fs.FS
 	EmbeddedPath   string
 	EmbeddedSubDir string
 	Path           string
 	FileName       string
 	Port           string
+	// AdminToken is the bearer token of the admin callers, that get the trace, the heap and the
+	// logs of a failed execution. $TWINROOM_ADMIN_TOKEN is used when empty.
+	AdminToken string
+	// Manifest describes the embedded contracts, the ones it does not know are introspected
+	Manifest *utils.Manifest
 }
 
+// AdminTokenEnv is the environment variable with the bearer token of the admin callers
+const AdminTokenEnv = "TWINROOM_ADMIN_TOKEN"
+
 const openapiCSS = `
 <style>
 	.HttpOperation__Description h1:before {
@@ -42,6 +54,9 @@ const openapiCSS = `
 // The documentation is available at the `/slang` endpoint.
 func StartHTTPServer(input HTTPInput) error {
 	ctx := context.Background()
+	if input.AdminToken == "" {
+		input.AdminToken = os.Getenv(AdminTokenEnv)
+	}
 
 	// Generate OpenAPI router
 
@@ -79,23 +94,57 @@ func StartHTTPServer(input HTTPInput) error {
 		}
 	})
 
+	return serve(ctx, mainRouter, input.Port, "/slang", "the API documentation")
+}
+
+// ContractsEndpoint is the endpoint serving the contracts list in list daemon mode
+const ContractsEndpoint = "/contracts"
+
+// StartListServer starts an HTTP server that serves the JSON document of the list command at
+// the ContractsEndpoint endpoint.
+func StartListServer(port string, contracts interface{}) error {
+	document, err := json.Marshal(contracts)
+	if err != nil {
+		return fmt.Errorf("error encoding the contracts list: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(ContractsEndpoint, contractsHandler(document))
+	return serve(context.Background(), mux, port, ContractsEndpoint, "the contracts list")
+}
+
+// contractsHandler serves the JSON document of the contracts list
+func contractsHandler(document []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(document); err != nil {
+			fmt.Printf("Failed to write HTTP response: %v\n", err)
+		}
+	}
+}
+
+// serve listens on port, or on an available port if it is taken, and serves handler
+func serve(ctx context.Context, handler http.Handler, port string, endpoint string, description string) error {
 	// Set up a listener on input port or an available port
-	listener, err := net.Listen("tcp", ":"+input.Port)
+	listener, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		listener, err = net.Listen("tcp", "localhost:0")
 		if err != nil {
 			return fmt.Errorf("error finding an open port: %v", err)
 		}
-		input.Port = fmt.Sprintf("%d", listener.Addr().(*net.TCPAddr).Port)
+		port = fmt.Sprintf("%d", listener.Addr().(*net.TCPAddr).Port)
 	}
 
 	// Print server information
-	fmt.Printf("Starting HTTP server on :%s\n", input.Port)
-	fmt.Printf("Access the API documentation at: http://localhost:%s/slang\n", input.Port)
+	fmt.Printf("Starting HTTP server on :%s\n", port)
+	fmt.Printf("Access %s at: http://localhost:%s%s\n", description, port, endpoint)
 
 	// Start the HTTP server
 	server := &http.Server{
-		Handler:      mainRouter,
+		Handler:      handler,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  30 * time.Second,
@@ -9,9 +9,12 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	swagger "github.com/davidebianchi/gswagger"
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+	"github.com/forkbombeu/twinroom/cmd/utils"
 	"github.com/stretchr/testify/require"
 )
 
@@ -117,3 +120,248 @@ Then print the data
 		require.Equal(t, "example data", response["test"])
 	})
 }
+
+type benchmarkPayload struct {
+	Name    string            `json:"name"`
+	Timeout int               `json:"timeout"`
+	Tags    []string          `json:"tags"`
+	Extra   map[string]string `json:"extra"`
+}
+
+var benchmarkBody = []byte(`{"name":"alice","timeout":60,"tags":["a","b"],"extra":{"k":"v"}}`)
+
+func TestCompileSchema(t *testing.T) {
+	schema, err := CompileSchema(&benchmarkPayload{})
+	require.NoError(t, err)
+	require.NoError(t, ValidateJSONAgainstSchema(benchmarkBody, schema))
+	require.Error(t, ValidateJSONAgainstSchema([]byte(`{"name":1}`), schema))
+
+	// A nil struct produces a nil schema that only checks the body is a JSON object
+	schema, err = CompileSchema(nil)
+	require.NoError(t, err)
+	require.Nil(t, schema)
+	require.NoError(t, ValidateJSONAgainstSchema([]byte(`{"anything":1}`), schema))
+	require.Error(t, ValidateJSONAgainstSchema([]byte(`not json`), schema))
+}
+
+// BenchmarkValidateJSONAgainstStruct measures validation when the schema is compiled on every request.
+func BenchmarkValidateJSONAgainstStruct(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if err := ValidateJSONAgainstStruct(benchmarkBody, &benchmarkPayload{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkValidateJSONAgainstSchema measures validation against a schema compiled once per route.
+func BenchmarkValidateJSONAgainstSchema(b *testing.B) {
+	schema, err := CompileSchema(&benchmarkPayload{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ValidateJSONAgainstSchema(benchmarkBody, schema); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGenerateOpenAPIRouterWithJSONSchema(t *testing.T) {
+	tempDir := t.TempDir()
+	contract := `Rule unknown ignore
+Given I have a 'string' named 'name'
+Then print the data
+`
+	metadata := `{
+    "description": "contract with a JSON Schema",
+    "arguments": [
+        {
+            "name": "<name>",
+            "schema": {"type": "string", "minLength": 3, "description": "name of the user"}
+        }
+    ],
+    "options": [
+        {
+            "name": "--tags",
+            "schema": {"type": "array", "items": {"type": "object", "properties": {"id": {"type": "integer"}}}}
+        },
+        {
+            "name": "--retries",
+            "schema": {"type": "integer", "minimum": 0}
+        }
+    ]
+}`
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "schema.slang"), []byte(contract), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "schema.metadata.json"), []byte(metadata), 0600))
+
+	muxRouter, err := GenerateOpenAPIRouter(context.Background(), HTTPInput{BinaryName: "TestBinary", Path: tempDir})
+	require.NoError(t, err)
+
+	t.Run("exposes the metadata schema", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		muxRouter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, swagger.DefaultJSONDocumentationPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var doc map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+		post := doc["paths"].(map[string]interface{})["/schema"].(map[string]interface{})["post"].(map[string]interface{})
+		body := post["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+		properties := body["properties"].(map[string]interface{})
+		require.Equal(t, float64(3), properties["name"].(map[string]interface{})["minLength"])
+		require.Equal(t, "object", properties["tags"].(map[string]interface{})["items"].(map[string]interface{})["type"])
+	})
+
+	t.Run("validates against the metadata schema", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		muxRouter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/schema", bytes.NewReader([]byte(`{"name":"al"}`))))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "validation failed")
+
+		w = httptest.NewRecorder()
+		muxRouter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/schema", bytes.NewReader([]byte(`{"name":"alice","tags":[{"id":1}]}`))))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = httptest.NewRecorder()
+		muxRouter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/schema", bytes.NewReader([]byte(`{"name":`))))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("validates the query parameters against the metadata schema", func(t *testing.T) {
+		for _, query := range []string{"name=al", "name=alice&retries=-1", "name=alice&retries=many"} {
+			w := httptest.NewRecorder()
+			muxRouter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/schema?"+query, nil))
+			require.Equal(t, http.StatusBadRequest, w.Code, query)
+			require.Contains(t, w.Body.String(), "validation failed", query)
+		}
+
+		w := httptest.NewRecorder()
+		muxRouter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/schema?name=alice&retries=3", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, float64(3), response["retries"])
+	})
+
+	t.Run("reports missing required inputs like the CLI", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		muxRouter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/schema", bytes.NewReader([]byte(`{"tags":[]}`))))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Equal(t, `missing required argument(s): "name"`, strings.TrimSpace(w.Body.String()))
+
+		w = httptest.NewRecorder()
+		muxRouter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/schema", nil))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Equal(t, `missing required argument(s): "name"`, strings.TrimSpace(w.Body.String()))
+
+		w = httptest.NewRecorder()
+		muxRouter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/schema", nil))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Equal(t, `missing required argument(s): "name"`, strings.TrimSpace(w.Body.String()))
+	})
+}
+
+func TestContractsHandler(t *testing.T) {
+	handler := contractsHandler([]byte(`[{"path":"test/hello","embedded":true}]`))
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, ContractsEndpoint, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.JSONEq(t, `[{"path":"test/hello","embedded":true}]`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, ContractsEndpoint, nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestExecutionErrorResponse(t *testing.T) {
+	tempDir := t.TempDir()
+	contract := `Given I have a 'string' named 'missing'
+Then print the data
+`
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "failing.slang"), []byte(contract), 0600))
+	// Without inputs in the metadata the request is valid and the execution fails
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "failing.metadata.json"), []byte(`{"description": "failing"}`), 0600))
+
+	muxRouter, err := GenerateOpenAPIRouter(context.Background(), HTTPInput{BinaryName: "TestBinary", Path: tempDir})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	muxRouter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/failing", nil))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var body executionErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.NotNil(t, body.Error)
+	require.NotEmpty(t, body.Error.Message)
+	require.Nil(t, body.Error.Heap)
+	require.Equal(t, []string{body.Error.Error()}, body.Message)
+}
+
+func TestExecutionErrorRedaction(t *testing.T) {
+	tempDir := t.TempDir()
+	contract := `Given I have a 'string' named 'missing'
+Then print the data
+`
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "failing.slang"), []byte(contract), 0600))
+	// Without inputs in the metadata the request is valid and the execution fails
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "failing.metadata.json"), []byte(`{"description": "failing"}`), 0600))
+	muxRouter, err := GenerateOpenAPIRouter(context.Background(), HTTPInput{BinaryName: "TestBinary", Path: tempDir, AdminToken: "admin-token"})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name          string
+		authorization string
+		admin         bool
+	}{
+		{name: "anonymous caller"},
+		{name: "wrong token", authorization: "Bearer other-token"},
+		{name: "admin caller", authorization: "Bearer admin-token", admin: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/failing", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			muxRouter.ServeHTTP(w, req)
+			require.Equal(t, http.StatusInternalServerError, w.Code)
+
+			var body executionErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			require.NotEmpty(t, body.Error.Message)
+			require.Equal(t, tt.admin, len(body.Logs) > 0)
+		})
+	}
+
+	t.Run("redacts the keys and the secret data", func(t *testing.T) {
+		execErr := &utils.ExecutionError{
+			Message: "cannot verify hunter2-password",
+			Trace:   []string{"+1  Given I have the 'keyring'", "[W] EDDSA-SECRET-KEY"},
+			Heap:    map[string]interface{}{"KEYS": map[string]interface{}{"eddsa": "EDDSA-SECRET-KEY"}},
+			Logs:    "[!] cannot verify hunter2-password\n[W] EDDSA-SECRET-KEY\n",
+		}
+		redactor := utils.NewInputRedactor(slangroom.SlangroomInput{
+			Data: `{"user": {"password": "hunter2-password", "name": "alice"}}`,
+			Keys: `{"keyring": {"eddsa": "EDDSA-SECRET-KEY"}}`,
+		}, []string{"user.password"})
+
+		body := executionErrorBody(execErr, redactor, true)
+		encoded, err := json.Marshal(body)
+		require.NoError(t, err)
+		require.NotContains(t, string(encoded), "hunter2-password")
+		require.NotContains(t, string(encoded), "EDDSA-SECRET-KEY")
+		require.Contains(t, string(encoded), utils.Redacted)
+		require.NotNil(t, body.Error.Heap)
+
+		body = executionErrorBody(execErr, redactor, false)
+		require.Nil(t, body.Error.Heap)
+		require.Nil(t, body.Error.Trace)
+		require.Empty(t, body.Logs)
+		require.Equal(t, []string{"cannot verify " + utils.Redacted}, body.Message)
+		// The original error is left untouched
+		require.Equal(t, "cannot verify hunter2-password", execErr.Message)
+	})
+}
@@ -1,14 +1,16 @@
 package httpserver
 
 import (
-	"bytes"
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/ForkbombEu/fouter"
@@ -26,6 +28,15 @@ type errorResponse struct {
 	Message []string `json:"message"`
 }
 
+// executionErrorResponse is the body of a failed execution, message holds the concise error
+// for the clients that only know errorResponse
+type executionErrorResponse struct {
+	Message []string              `json:"message"`
+	Error   *utils.ExecutionError `json:"error"`
+	// Logs are the zenroom logs, sent only to the admin callers
+	Logs []string `json:"logs,omitempty"`
+}
+
 type outputResponse struct {
 	Output []string `json:"output"`
 }
@@ -33,33 +44,36 @@ type outputResponse struct {
 // GenerateOpenAPIRouter generates an OpenAPI router with routes defined based on slangroom contracts.
 func GenerateOpenAPIRouter(ctx context.Context, input HTTPInput) (*mux.Router, error) {
 	muxRouter := mux.NewRouter()
-	router, _ := swagger.NewRouter(gorilla.NewRouter(muxRouter), swagger.Options{
-		Context: ctx,
-		Openapi: &openapi3.T{
-			Info: &openapi3.Info{
-				Title:   input.BinaryName,
-				Version: "1.0.0",
-			},
-			Tags: openapi3.Tags{
-				{
-					Name:        "📑 Zencodes",
-					Description: "Endpoints generated over the Zencode smart contracts",
-				},
+	// keep a reference to the spec to set the schemas declared in metadata
+	spec := &openapi3.T{
+		Info: &openapi3.Info{
+			Title:   input.BinaryName,
+			Version: "1.0.0",
+		},
+		Tags: openapi3.Tags{
+			{
+				Name:        "📑 Zencodes",
+				Description: "Endpoints generated over the Zencode smart contracts",
 			},
 		},
+	}
+	router, _ := swagger.NewRouter(gorilla.NewRouter(muxRouter), swagger.Options{
+		Context: ctx,
+		Openapi: spec,
 	})
 	folderPath := input.EmbeddedPath
 	if input.EmbeddedSubDir != "" {
 		folderPath = input.EmbeddedPath + "/" + input.EmbeddedSubDir
 	}
-	err := fouter.CreateFileRouter(input.Path, input.EmbeddedFolder, folderPath, func(file fouter.SlangFile) {
+	var routeErr error
+	addRoutes := func(file fouter.SlangFile) {
 		var filename string
 		if input.FileName == "" {
 			filename = strings.TrimSuffix(file.FileName, filepath.Ext(file.FileName))
 		} else {
 			filename = input.FileName
 		}
-		if filename == strings.TrimSuffix(file.FileName, filepath.Ext(file.FileName)) {
+		if filename == strings.TrimSuffix(file.FileName, filepath.Ext(file.FileName)) && routeErr == nil {
 			var err error
 			var relativePath string
 			if input.EmbeddedPath != "" {
@@ -69,24 +83,52 @@ func GenerateOpenAPIRouter(ctx context.Context, input HTTPInput) (*mux.Router, e
 				relativePath = strings.TrimSuffix(filepath.Join(file.Dir, file.FileName), filepath.Ext(file.FileName))
 			}
 			metadataPath := filepath.Join(file.Dir, filename+".metadata.json")
+			if !file.IsEmbedded {
+				metadataPath = filepath.Join(input.Path, metadataPath)
+			}
 			var dynamicStruct interface{}
+			var inputSchema map[string]interface{}
 			var introspectionData string
-			metadata, err := utils.LoadMetadata(input.EmbeddedFolder, metadataPath)
+			var metadata *utils.CommandMetadata
+			entry, inManifest := input.Manifest.Entry(filepath.Join(file.Dir, file.FileName), file.Content)
+			if file.IsEmbedded && inManifest {
+				metadata, err = entry.LoadMetadata()
+			} else {
+				metadata, err = utils.LoadMetadata(input.EmbeddedFolder, metadataPath)
+			}
 			if err != nil && err.Error() != "metadata file not found" {
 				log.Printf("WARNING: error in metadata for contracts: %s\n", file.FileName)
 				log.Println(err)
 			} else if err == nil {
-				dynamicStruct, _ = utils.GenerateStruct(*metadata, "")
-			} else {
-				introspectionData, err = slangroom.Introspect(file.Content)
-				if err == nil {
-					introspectionData = utils.CleanIntrospection(file.Content, introspectionData)
+				// JSON Schema from metadata takes precedence over the generated struct
+				var ok bool
+				if file.IsEmbedded && inManifest {
+					inputSchema, ok = entry.Schema, entry.Schema != nil
 				} else {
-					introspectionData = ""
+					inputSchema, ok = utils.InputSchema(metadata)
+				}
+				if !ok {
+					dynamicStruct, _ = utils.GenerateStruct(*metadata, "")
+				}
+			} else {
+				introspectionData, err = utils.IntrospectContract(file.Content)
+				if err != nil {
+					log.Printf("WARNING: the inputs of %s are unknown: %v\n", file.FileName, err)
 				}
 				dynamicStruct, _ = utils.GenerateStruct(utils.CommandMetadata{}, introspectionData)
 			}
-			_, err = router.AddRoute(http.MethodPost, "/"+relativePath, gorilla.HandlerFunc(createSlangroomHandler(file, dynamicStruct)), swagger.Definitions{
+			// Compile the request schema once, so that requests only pay for the validation
+			var schema *jsschema.Schema
+			if inputSchema != nil {
+				schema, err = utils.CompileJSONSchema(inputSchema)
+			} else {
+				schema, err = CompileSchema(dynamicStruct)
+			}
+			if err != nil {
+				routeErr = fmt.Errorf("failed to compile schema for %s: %w", relativePath, err)
+				return
+			}
+			_, err = router.AddRoute(http.MethodPost, "/"+relativePath, gorilla.HandlerFunc(createSlangroomHandler(file, metadata, schema, input.AdminToken)), swagger.Definitions{
 				Tags: []string{"📑 Zencodes"},
 				RequestBody: &swagger.ContentValue{
 					Content: swagger.Content{
@@ -112,7 +154,13 @@ func GenerateOpenAPIRouter(ctx context.Context, input HTTPInput) (*mux.Router, e
 			if err != nil {
 				return
 			}
-			_, err = router.AddRoute(http.MethodGet, "/"+relativePath, gorilla.HandlerFunc(createSlangroomHandler(file, nil)), swagger.Definitions{
+			if inputSchema != nil {
+				if err = setRequestBodySchema(spec, "/"+relativePath, inputSchema); err != nil {
+					routeErr = fmt.Errorf("invalid schema for %s: %w", relativePath, err)
+					return
+				}
+			}
+			_, err = router.AddRoute(http.MethodGet, "/"+relativePath, gorilla.HandlerFunc(createSlangroomHandler(file, metadata, schema, input.AdminToken)), swagger.Definitions{
 				Tags: []string{"📑 Zencodes"},
 				Querystring: func() swagger.ParameterValue {
 					queryParameters := swagger.ParameterValue{}
@@ -122,7 +170,7 @@ func GenerateOpenAPIRouter(ctx context.Context, input HTTPInput) (*mux.Router, e
 							name := utils.NormalizeArgumentName(arg.Name)
 							queryParameters[name] = swagger.Parameter{
 								Schema: &swagger.Schema{
-									Value:                     utils.CreateDefaultValue(arg.Type, "", utils.ParseObjectProperties(arg.Properties)...),
+									Value:                     utils.CreateDefaultValue(utils.ArgumentType(arg), "", utils.ParseObjectProperties(arg.Properties)...),
 									AllowAdditionalProperties: true,
 								},
 								Description: arg.Description,
@@ -177,12 +225,29 @@ func GenerateOpenAPIRouter(ctx context.Context, input HTTPInput) (*mux.Router, e
 			if err != nil {
 				return
 			}
+			if inputSchema != nil {
+				if err = setQuerySchemas(spec, "/"+relativePath, inputSchema); err != nil {
+					routeErr = fmt.Errorf("invalid schema for %s: %w", relativePath, err)
+					return
+				}
+			}
 		}
-	})
+	}
+	// The embedded contracts are walked on their file system, that can also be a bundle
+	var err error
+	if input.EmbeddedFolder != nil {
+		err = utils.WalkContracts(input.EmbeddedFolder, folderPath, addRoutes)
+	}
+	if err == nil && input.Path != "" {
+		err = fouter.CreateFileRouter(input.Path, nil, folderPath, addRoutes)
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("error creating file router: %v", err)
 	}
+	if routeErr != nil {
+		return nil, routeErr
+	}
 
 	// Expose OpenAPI documentation
 	err = router.GenerateAndExposeOpenapi()
@@ -191,30 +256,132 @@ func GenerateOpenAPIRouter(ctx context.Context, input HTTPInput) (*mux.Router, e
 	}
 	return muxRouter, nil
 }
-func getQueryParams(r *http.Request) map[string]interface{} {
+func getQueryParams(r *http.Request, metadata *utils.CommandMetadata, schema *jsschema.Schema) map[string]interface{} {
 	data := make(map[string]interface{})
+	arrays := arrayInputs(metadata)
+	properties := resolveSchema(schema)
 
 	// Get the query string from the URL
 	q := r.URL.Query()
 
 	// Iterate over the query parameters
 	for key, values := range q {
+		var property *jsschema.Schema
+		if properties != nil {
+			property = resolveSchema(properties.Properties[key])
+		}
+		if arrays[key] || hasSchemaType(property, "array") {
+			// Repeated parameters of array inputs are all kept
+			itemsSchema := schemaItems(property)
+			items := make([]interface{}, len(values))
+			for i, v := range values {
+				items[i] = queryValue(itemsSchema, v)
+			}
+			data[key] = items
+			continue
+		}
 		// Use the first value if there are multiple values for the same key
 		if len(values) > 0 {
-			data[key] = values[0]
+			data[key] = queryValue(property, values[0])
 		}
 	}
 
 	return data
 }
 
-func createSlangroomHandler(file fouter.SlangFile, dynamicStruct interface{}) http.HandlerFunc {
+// queryValue converts a query parameter into the JSON type the schema of the route expects, so
+// that GET requests are validated like the POST ones. A value that does not convert is kept as
+// a string and the validation rejects it.
+func queryValue(schema *jsschema.Schema, raw string) interface{} {
+	if schema == nil || hasSchemaType(schema, "string") {
+		return raw
+	}
+	for _, typ := range schema.Types {
+		if typ == "null" {
+			continue
+		}
+		if value, err := utils.ParseTypedValue(typ, raw); err == nil {
+			return value
+		}
+	}
+	return raw
+}
+
+// resolveSchema follows the references of a compiled schema, like the $defs of a reflected struct
+func resolveSchema(schema *jsschema.Schema) *jsschema.Schema {
+	for schema != nil && schema.Ref != nil {
+		schema = schema.Ref
+	}
+	return schema
+}
+
+// schemaItems returns the schema of the elements of an array schema
+func schemaItems(schema *jsschema.Schema) *jsschema.Schema {
+	if schema == nil {
+		return nil
+	}
+	if schema.Items2020 != nil {
+		return resolveSchema(schema.Items2020)
+	}
+	items, _ := schema.Items.(*jsschema.Schema)
+	return resolveSchema(items)
+}
+
+func hasSchemaType(schema *jsschema.Schema, typ string) bool {
+	return schema != nil && slices.Contains(schema.Types, typ)
+}
+
+// arrayInputs returns the names of the arguments and options of array type
+func arrayInputs(metadata *utils.CommandMetadata) map[string]bool {
+	arrays := make(map[string]bool)
+	if metadata == nil {
+		return arrays
+	}
+	for _, arg := range metadata.Arguments {
+		if utils.ArgumentType(arg) == "array" {
+			arrays[utils.NormalizeArgumentName(arg.Name)] = true
+		}
+	}
+	for _, opt := range metadata.Options {
+		if strings.ToLower(opt.Type) == "array" {
+			arrays[utils.GetFlagName(opt.Name)] = true
+		}
+	}
+	return arrays
+}
+
+func createSlangroomHandler(file fouter.SlangFile, metadata *utils.CommandMetadata, schema *jsschema.Schema, adminToken string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		handleSlangroomRequest(file, dynamicStruct, w, r)
+		handleSlangroomRequest(file, metadata, schema, isAdmin(r, adminToken), w, r)
 	}
 }
 
-func handleSlangroomRequest(file fouter.SlangFile, dynamicStruct interface{}, w http.ResponseWriter, r *http.Request) {
+// isAdmin reports whether the request carries the bearer token of the admin callers, nobody is
+// an admin without token
+func isAdmin(r *http.Request, adminToken string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+// executionErrorBody returns the body of a failed execution with the secrets redacted. Only the
+// admin callers get the trace, the heap and the logs, the others get the message and the line.
+func executionErrorBody(execErr *utils.ExecutionError, redactor *utils.Redactor, admin bool) executionErrorResponse {
+	redacted := redactor.RedactError(execErr)
+	body := executionErrorResponse{Message: []string{redacted.Error()}}
+	if !admin {
+		body.Error = &utils.ExecutionError{Message: redacted.Message, Line: redacted.Line, Statement: redacted.Statement}
+		return body
+	}
+	body.Error = redacted
+	for _, line := range strings.Split(redacted.Logs, "\n") {
+		if strings.TrimSpace(line) != "" {
+			body.Logs = append(body.Logs, line)
+		}
+	}
+	return body
+}
+
+func handleSlangroomRequest(file fouter.SlangFile, metadata *utils.CommandMetadata, schema *jsschema.Schema, admin bool, w http.ResponseWriter, r *http.Request) {
 	var input map[string]interface{}
 
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -234,14 +401,9 @@ func handleSlangroomRequest(file fouter.SlangFile, dynamicStruct interface{}, w
 				return
 			}
 
-			// Decode into dynamicStruct for validation
-			if err := ValidateJSONAgainstStruct(bodyBytes, dynamicStruct); err != nil {
-				http.Error(w, fmt.Sprintf("Invalid JSON payload for validation: %v", err), http.StatusInternalServerError)
-				return
-			}
 			// Decode into a generic map for further processing
 			if err := json.Unmarshal(bodyBytes, &input); err != nil {
-				http.Error(w, fmt.Sprintf("Invalid JSON payload: %v", err), http.StatusInternalServerError)
+				http.Error(w, fmt.Sprintf("Invalid JSON payload: %v", err), http.StatusBadRequest)
 				return
 			}
 		}
@@ -249,7 +411,13 @@ func handleSlangroomRequest(file fouter.SlangFile, dynamicStruct interface{}, w
 
 	// Handle GET request with query parameters
 	if r.Method == http.MethodGet {
-		input = getQueryParams(r)
+		input = getQueryParams(r, metadata, schema)
+	}
+
+	// Check the required inputs first, so that the error matches the CLI one
+	if err := utils.CheckRequiredInputs(metadata, input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	data, err := json.Marshal(input)
@@ -257,6 +425,11 @@ func handleSlangroomRequest(file fouter.SlangFile, dynamicStruct interface{}, w
 		http.Error(w, fmt.Sprintf("Failed to marshal input: %v", err), http.StatusInternalServerError)
 		return
 	}
+	// Validate the input of every method against the schema compiled for this route
+	if err := ValidateJSONAgainstSchema(data, schema); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid input: %v", err), http.StatusBadRequest)
+		return
+	}
 
 	slangroomInput := slangroom.SlangroomInput{
 		Contract: file.Content,
@@ -264,10 +437,17 @@ func handleSlangroomRequest(file fouter.SlangFile, dynamicStruct interface{}, w
 	}
 
 	// Execute the slangroom contract
-	output, err := slangroom.Exec(slangroomInput)
+	output, err := utils.ExecContract(slangroomInput, 0)
 	if err != nil {
-		log.Printf("Execution error for file %s: %v", file.FileName, output.Logs)
-		http.Error(w, fmt.Sprintf("Execution error: %v", output.Logs), http.StatusInternalServerError)
+		execErr := utils.ParseExecutionError(file.Content, output.Logs, err)
+		// The keys and the secret data never reach the logs or the client
+		redactor := utils.NewInputRedactor(slangroomInput, utils.SecretPaths(metadata))
+		log.Printf("Execution error for file %s: %v", file.FileName, redactor.Redact(execErr.Error()))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		if err := json.NewEncoder(w).Encode(executionErrorBody(execErr, redactor, admin)); err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
 		return
 	}
 
@@ -293,37 +473,112 @@ func handleSlangroomRequest(file fouter.SlangFile, dynamicStruct interface{}, w
 	}
 }
 
-// Validate the request body against the json schema
-func ValidateJSONAgainstStruct(data []byte, schemaStruct interface{}) error {
+// CompileSchema reflects the JSON schema of the given struct and compiles it, so that
+// it can be reused to validate every request of a route. A nil struct yields a nil schema.
+func CompileSchema(schemaStruct interface{}) (*jsschema.Schema, error) {
+	if schemaStruct == nil {
+		return nil, nil
+	}
 	// Generate JSON schema from the struct
-	schema := jsonschema.Reflect(schemaStruct)
-	// Marshal schema to JSON
-	schemaJSON, err := json.Marshal(schema)
+	return utils.CompileJSONSchema(jsonschema.Reflect(schemaStruct))
+}
+
+// toOpenAPISchema converts a JSON Schema into an OpenAPI schema, dropping the keywords
+// that are not allowed inside an OpenAPI document.
+func toOpenAPISchema(schema map[string]interface{}) (*openapi3.Schema, error) {
+	cleaned := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		if key == "$schema" || key == "$id" {
+			continue
+		}
+		cleaned[key] = value
+	}
+	data, err := json.Marshal(cleaned)
 	if err != nil {
-		return fmt.Errorf("failed to generate schema: %w", err)
+		return nil, err
 	}
-
-	// Compile the schema using jsonschema/v5
-	compiler := jsschema.NewCompiler()
-	if err := compiler.AddResource("schema.json", bytes.NewReader(schemaJSON)); err != nil {
-		return fmt.Errorf("failed to add schema resource: %w", err)
+	oasSchema := openapi3.NewSchema()
+	if err := oasSchema.UnmarshalJSON(data); err != nil {
+		return nil, err
 	}
+	return oasSchema, nil
+}
 
-	compiledSchema, err := compiler.Compile("schema.json")
+// setRequestBodySchema replaces the JSON request body schema of the POST operation on path
+func setRequestBodySchema(spec *openapi3.T, path string, schema map[string]interface{}) error {
+	oasSchema, err := toOpenAPISchema(schema)
 	if err != nil {
-		return fmt.Errorf("failed to compile schema: %w", err)
+		return err
+	}
+	item := spec.Paths.Value(path)
+	if item == nil || item.Post == nil || item.Post.RequestBody == nil {
+		return fmt.Errorf("no POST operation for %s", path)
+	}
+	item.Post.RequestBody.Value.Content = openapi3.NewContentWithJSONSchema(oasSchema)
+	return nil
+}
+
+// setQuerySchemas replaces the schema of each query parameter of the GET operation on path
+// with the matching property of the JSON Schema, adding the missing ones.
+func setQuerySchemas(spec *openapi3.T, path string, schema map[string]interface{}) error {
+	item := spec.Paths.Value(path)
+	if item == nil || item.Get == nil {
+		return fmt.Errorf("no GET operation for %s", path)
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property, ok := properties[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		oasSchema, err := toOpenAPISchema(property)
+		if err != nil {
+			return fmt.Errorf("property %s: %w", name, err)
+		}
+		param := item.Get.Parameters.GetByInAndName(openapi3.ParameterInQuery, name)
+		if param == nil {
+			param = openapi3.NewQueryParameter(name)
+			item.Get.AddParameter(param)
+		}
+		if description, ok := property["description"].(string); ok && param.Description == "" {
+			param.Description = description
+		}
+		param.Schema = openapi3.NewSchemaRef("", oasSchema)
 	}
+	return nil
+}
 
+// ValidateJSONAgainstSchema validates the request body against an already compiled schema.
+// A nil schema accepts any JSON object.
+func ValidateJSONAgainstSchema(data []byte, schema *jsschema.Schema) error {
 	// Unmarshal JSON data into a map[string]interface{} for validation
 	var jsonData map[string]interface{}
 	if err := json.Unmarshal(data, &jsonData); err != nil {
 		return fmt.Errorf("failed to unmarshal JSON for validation: %w", err)
 	}
+	if schema == nil {
+		return nil
+	}
 
 	// Validate the input JSON data
-	if err := compiledSchema.Validate(jsonData); err != nil {
+	if err := schema.Validate(jsonData); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
 	return nil
 }
+
+// Validate the request body against the json schema, compiling it on each call.
+// Prefer CompileSchema and ValidateJSONAgainstSchema when the schema is reused.
+func ValidateJSONAgainstStruct(data []byte, schemaStruct interface{}) error {
+	compiledSchema, err := CompileSchema(schemaStruct)
+	if err != nil {
+		return err
+	}
+	return ValidateJSONAgainstSchema(data, compiledSchema)
+}
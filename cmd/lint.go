@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/forkbombeu/twinroom/cmd/utils"
+	"github.com/spf13/cobra"
+)
+
+var lintFormat string
+
+func init() {
+	lintCmd.Flags().StringVarP(&lintFormat, "format", "f", "text", "Output format, text or sarif")
+	runCmd.AddCommand(lintCmd)
+}
+
+// lintCmd introspects every contract in the folder, or the embedded ones if no folder is specified,
+// and cross-checks its inputs with the metadata and the side data files.
+var lintCmd = &cobra.Command{
+	Use:   "lint [folder]",
+	Short: "Check the contracts in the folder or the embedded ones if no folder is specified against their metadata",
+	Args:  cobra.MaximumNArgs(1),
+	PreRunE: func(_ *cobra.Command, _ []string) error {
+		if lintFormat != "text" && lintFormat != "sarif" {
+			return fmt.Errorf("invalid format %s, use text or sarif", lintFormat)
+		}
+		return nil
+	},
+	Run: func(_ *cobra.Command, args []string) {
+		var findings []utils.LintFinding
+		var err error
+		if len(args) == 0 {
+			findings, err = utils.LintFS(contracts, "contracts")
+		} else {
+			findings, err = utils.LintFS(os.DirFS(args[0]), ".")
+			// Report the paths as given on the command line
+			for i := range findings {
+				findings[i].File = filepath.Join(args[0], findings[i].File)
+			}
+		}
+		if err != nil {
+			fail(ExitNotFound, "Error: %v\n", err)
+		}
+
+		errors := 0
+		for _, finding := range findings {
+			if finding.Level == "error" {
+				errors++
+			}
+		}
+		if lintFormat == "sarif" {
+			report, err := utils.SARIFReport(filepath.Base(os.Args[0]), findings)
+			if err != nil {
+				fail(ExitFailure, "Error: %v\n", err)
+			}
+			fmt.Println(string(report))
+		} else {
+			for _, finding := range findings {
+				fmt.Println(finding)
+			}
+			fmt.Printf("%d error(s), %d warning(s)\n", errors, len(findings)-errors)
+		}
+		if errors > 0 {
+			os.Exit(ExitMetadata)
+		}
+	},
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/forkbombeu/twinroom/cmd/utils"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	metadataCmd.AddCommand(metadataValidateCmd)
+	metadataCmd.AddCommand(metadataSchemaCmd)
+	runCmd.AddCommand(metadataCmd)
+}
+
+// metadataCmd groups the commands working on the contracts metadata files.
+var metadataCmd = &cobra.Command{
+	Use:   "metadata",
+	Short: "Validate the metadata files of the contracts",
+}
+
+// metadataValidateCmd checks every metadata file in the folder, or the embedded ones if no folder
+// is specified, and reports each problem with its file and line.
+var metadataValidateCmd = &cobra.Command{
+	Use:   "validate [folder]",
+	Short: "Validate the metadata files in the folder or the embedded ones if no folder is specified",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		var problems []utils.MetadataProblem
+		var err error
+		if len(args) == 0 {
+			problems, err = utils.ValidateMetadataFS(contracts, "contracts")
+		} else {
+			problems, err = utils.ValidateMetadataFS(os.DirFS(args[0]), ".")
+		}
+		if err != nil {
+			fail(ExitNotFound, "Error: %v\n", err)
+		}
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			fmt.Printf("%d problem(s) found\n", len(problems))
+			os.Exit(ExitMetadata)
+		}
+		fmt.Println("All metadata files are valid")
+	},
+}
+
+// metadataSchemaCmd prints the JSON Schema of the metadata files.
+var metadataSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the metadata files",
+	Args:  cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		fmt.Print(string(utils.MetadataSchema()))
+	},
+}
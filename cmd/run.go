@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ForkbombEu/fouter"
+	"github.com/forkbombeu/twinroom/cmd/utils"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	runCmd.AddCommand(runContractCmd)
+}
+
+// runContractCmd executes a contract of a folder with the arguments and flags of its metadata, or of
+// its introspection, like the embedded contracts do. The command of the contract is added by
+// addFolderCommand under a command named after the folder before the flags are parsed, this command
+// only runs when it could not be added.
+var runContractCmd = &cobra.Command{
+	Use:                "run <folder> <contract> [arguments and flags of the contract]",
+	Short:              "Execute a contract in the folder with the arguments and flags of its metadata",
+	Args:               cobra.MinimumNArgs(1),
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		positionals := utils.PositionalArgs(runCmd.PersistentFlags(), args)
+		if len(positionals) == 0 && (slices.Contains(args, "--help") || slices.Contains(args, "-h")) {
+			if err := cmd.Help(); err != nil {
+				log.Println("Error:", err)
+			}
+			return
+		}
+		if len(positionals) >= 2 {
+			if _, err := newFolderCommand(args[positionals[0]], args[positionals[1]]); err != nil {
+				fail(exitCode(err), "Error: %v\n", err)
+			}
+		}
+		fail(ExitUsage, "Error: %s requires a folder and a contract\n", cmd.CommandPath())
+	},
+}
+
+// addFolderCommand adds the command of the contract of a folder when args, the arguments of the
+// command line, run or inspect it, so that cobra parses the flags of the contract and the ones of
+// the root command at once. It returns the arguments to execute: inspect is replaced by the
+// command of the contract with --dry-run.
+func addFolderCommand(args []string) []string {
+	cmd, rest, err := runCmd.Find(args)
+	if err != nil || (cmd != runContractCmd && cmd != inspectCmd) {
+		return args
+	}
+	positionals := utils.PositionalArgs(runCmd.PersistentFlags(), rest)
+	if cmd == inspectCmd {
+		if len(positionals) == 0 {
+			return args
+		}
+		i := positionals[0]
+		contractArgs := strings.Split(rest[i], "/")
+		if isDir, err := utils.IsDir(rest[i]); err == nil && isDir {
+			contractArgs = []string{runContractCmd.Name(), rest[i]}
+		}
+		contractArgs = append(append([]string{"--dry-run"}, contractArgs...), rest[i+1:]...)
+		return addFolderCommand(append(slices.Clone(rest[:i]), contractArgs...))
+	}
+	if len(positionals) < 2 {
+		return args
+	}
+	if folderCmd, err := newFolderCommand(rest[positionals[0]], rest[positionals[1]]); err == nil {
+		runContractCmd.AddCommand(folderCmd)
+	}
+	return args
+}
+
+// newFolderCommand returns the command of the folder with the command of its contract, the errors
+// carry the exit code
+func newFolderCommand(folder string, contract string) (*cobra.Command, error) {
+	if isDir, err := utils.IsDir(folder); err != nil || !isDir {
+		return nil, &exitError{code: ExitNotFound, err: fmt.Errorf("folder %s not found", folder)}
+	}
+	contractPath := strings.TrimSuffix(filepath.ToSlash(contract), ".slang")
+	var fileCmd *cobra.Command
+	err := fouter.CreateFileRouter(folder, nil, "", func(file fouter.SlangFile) {
+		relativePath := filepath.ToSlash(filepath.Join(file.Dir, file.FileName))
+		if fileCmd == nil && strings.TrimSuffix(relativePath, filepath.Ext(relativePath)) == contractPath {
+			fileCmd = newContractCommand(file, contract, folder)
+		}
+	})
+	if err != nil {
+		return nil, &exitError{code: ExitNotFound, err: err}
+	}
+	if fileCmd == nil {
+		return nil, &exitError{code: ExitNotFound, err: fmt.Errorf("contract %s not found in folder %s", contract, folder)}
+	}
+	// The name of a command ends at the first space of its use line, the aliases match the folder
+	// and the contract also when they have spaces
+	fileCmd.Aliases = append(fileCmd.Aliases, contract)
+	folderCmd := &cobra.Command{
+		Use:     folder,
+		Aliases: []string{folder},
+		Short:   fmt.Sprintf("Contracts in %s", folder),
+	}
+	folderCmd.AddCommand(fileCmd)
+	return folderCmd, nil
+}
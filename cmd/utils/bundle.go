@@ -0,0 +1,259 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ForkbombEu/fouter"
+	"github.com/spf13/pflag"
+)
+
+// BundleEnv is the environment variable with the archive of contracts used in place of the
+// embedded ones, like the --bundle flag
+const BundleEnv = "TWINROOM_BUNDLE"
+
+// maxBundleSize is the size limit of the uncompressed content of a bundle
+const maxBundleSize = 64 << 20
+
+// bundleRoot is the folder of the contracts in a bundle, like in the embedded folder
+const bundleRoot = "contracts"
+
+// OpenBundle mounts a .zip, .tar.gz or .tgz archive of contracts as a file system, without
+// extracting it on the disk. The contracts are in the contracts folder of the file system, like
+// the embedded ones: the archive either holds that folder or the contracts at its root.
+func OpenBundle(archivePath string) (fs.FS, error) {
+	content, err := os.ReadFile(archivePath) // #nosec G304 -- the bundle is chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	var files map[string][]byte
+	switch name := strings.ToLower(archivePath); {
+	case strings.HasSuffix(name, ".zip"):
+		files, err = readZipBundle(content)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		files, err = readTarBundle(content)
+	default:
+		return nil, fmt.Errorf("unsupported bundle %s, use a .zip, .tar.gz or .tgz archive", archivePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle %s: %w", archivePath, err)
+	}
+
+	// The contracts at the root of the archive are moved in the contracts folder
+	prefix := bundleRoot + "/"
+	contracts := 0
+	for name := range files {
+		if strings.HasPrefix(name, bundleRoot+"/") {
+			prefix = ""
+		}
+		if path.Ext(name) == ".slang" {
+			contracts++
+		}
+	}
+	if contracts == 0 {
+		return nil, fmt.Errorf("no contracts found in bundle %s", archivePath)
+	}
+
+	// The files are written in a zip in memory, whose reader is a read-only file system
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := writer.CreateHeader(&zip.FileHeader{Name: prefix + name, Method: zip.Store})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
+
+// readZipBundle returns the regular files of a zip archive
+func readZipBundle(content []byte) (map[string][]byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	var size int64
+	for _, file := range reader.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := readBundleFile(rc, &size)
+		closeErr := rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if closeErr != nil {
+			return nil, closeErr
+		}
+		if err := addBundleFile(files, file.Name, data); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// readTarBundle returns the regular files of a gzipped tar archive
+func readTarBundle(content []byte) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = gz.Close() }()
+	reader := tar.NewReader(gz)
+	files := make(map[string][]byte)
+	var size int64
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := readBundleFile(reader, &size)
+		if err != nil {
+			return nil, err
+		}
+		if err := addBundleFile(files, header.Name, data); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readBundleFile reads a file of the archive, size counts the bytes read from the whole archive
+func readBundleFile(r io.Reader, size *int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBundleSize-*size+1))
+	if err != nil {
+		return nil, err
+	}
+	*size += int64(len(data))
+	if *size > maxBundleSize {
+		return nil, fmt.Errorf("the content is larger than %d bytes", maxBundleSize)
+	}
+	return data, nil
+}
+
+// addBundleFile adds a file to the files of the bundle, the names that leave the archive are
+// rejected
+func addBundleFile(files map[string][]byte, name string, data []byte) error {
+	cleaned := path.Clean(strings.TrimPrefix(name, "./"))
+	if !fs.ValidPath(cleaned) || cleaned == "." {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	files[cleaned] = data
+	return nil
+}
+
+// BundleArg returns the value of the --bundle flag given before the command in args, the
+// arguments of the command line without the program name. The commands of the contracts are
+// created before cobra parses the flags, so the flags of the root command in flags are used to
+// skip their values. It returns an empty string when there is no --bundle before the command.
+func BundleArg(flags *pflag.FlagSet, args []string) (string, error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || arg == "-" || !strings.HasPrefix(arg, "-") {
+			// The flags of the root command end at the first command or argument
+			return "", nil
+		}
+		if value, ok := strings.CutPrefix(arg, "--bundle="); ok {
+			if value == "" {
+				return "", fmt.Errorf("flag needs an argument: --bundle")
+			}
+			return value, nil
+		}
+		if arg == "--bundle" {
+			if i+1 == len(args) || args[i+1] == "" || strings.HasPrefix(args[i+1], "--") {
+				return "", fmt.Errorf("flag needs an argument: --bundle")
+			}
+			return args[i+1], nil
+		}
+		if takesValue(flags, arg) {
+			// The value of the flag is the next argument, even when it looks like a flag
+			i++
+		}
+	}
+	return "", nil
+}
+
+// PositionalArgs returns the indexes in args of the arguments that are neither flags nor values of
+// the flags in flags, like the folder and the contract of the run command. The arguments after --
+// are all positional.
+func PositionalArgs(flags *pflag.FlagSet, args []string) []int {
+	var positionals []int
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			for i++; i < len(args); i++ {
+				positionals = append(positionals, i)
+			}
+		case arg == "-" || !strings.HasPrefix(arg, "-"):
+			positionals = append(positionals, i)
+		case takesValue(flags, arg):
+			i++
+		}
+	}
+	return positionals
+}
+
+// takesValue reports whether the flag arg of flags is followed by its value, like --data value
+// and unlike --data=value or --dry-run
+func takesValue(flags *pflag.FlagSet, arg string) bool {
+	var flag *pflag.Flag
+	if name, ok := strings.CutPrefix(arg, "--"); ok {
+		if strings.Contains(name, "=") {
+			return false
+		}
+		flag = flags.Lookup(name)
+	} else if len(arg) == 2 {
+		flag = flags.ShorthandLookup(arg[1:])
+	}
+	return flag != nil && flag.NoOptDefVal == ""
+}
+
+// WalkContracts calls handler for each contract under dir of fsys, like fouter.CreateFileRouter
+// does for the embedded contracts
+func WalkContracts(fsys fs.FS, dir string, handler func(fouter.SlangFile)) error {
+	return fs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".slang") {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		handler(fouter.SlangFile{
+			Path:       p,
+			Content:    string(content),
+			FileName:   path.Base(p),
+			Dir:        path.Dir(p),
+			IsEmbedded: true,
+		})
+		return nil
+	})
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ForkbombEu/fouter"
+	"github.com/spf13/pflag"
+)
+
+func writeZip(t *testing.T, archivePath string, files map[string]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTarGz(t *testing.T, archivePath string, files map[string]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	writer := tar.NewWriter(gz)
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenBundle(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"greet/hello.slang":         "Given nothing\nThen print the string 'hi'\n",
+		"greet/hello.metadata.json": `{"description": "hello"}`,
+	}
+	zipPath := filepath.Join(tempDir, "contracts.zip")
+	writeZip(t, zipPath, files)
+	tarPath := filepath.Join(tempDir, "contracts.tar.gz")
+	writeTarGz(t, tarPath, map[string]string{
+		"./contracts/greet/hello.slang":         files["greet/hello.slang"],
+		"./contracts/greet/hello.metadata.json": files["greet/hello.metadata.json"],
+	})
+
+	for _, archivePath := range []string{zipPath, tarPath} {
+		bundle, err := OpenBundle(archivePath)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", archivePath, err)
+		}
+		metadata, err := LoadMetadata(bundle, "contracts/greet/hello.metadata.json")
+		if err != nil || metadata.Description != "hello" {
+			t.Errorf("Expected the metadata in the contracts folder of %s, got %+v, %v", archivePath, metadata, err)
+		}
+		var found []fouter.SlangFile
+		if err := WalkContracts(bundle, "contracts", func(file fouter.SlangFile) { found = append(found, file) }); err != nil {
+			t.Fatalf("Unexpected error walking %s: %v", archivePath, err)
+		}
+		if len(found) != 1 || found[0].Dir != "contracts/greet" || found[0].FileName != "hello.slang" || !found[0].IsEmbedded || found[0].Content != files["greet/hello.slang"] {
+			t.Errorf("Expected the hello contract in %s, got %+v", archivePath, found)
+		}
+
+		if err := fstest.TestFS(bundle, "contracts/greet/hello.slang", "contracts/greet/hello.metadata.json"); err != nil {
+			t.Errorf("Expected a valid file system for %s: %v", archivePath, err)
+		}
+	}
+
+	invalid := filepath.Join(tempDir, "invalid.tar.gz")
+	writeTarGz(t, invalid, map[string]string{"../outside.slang": "Given nothing"})
+	if _, err := OpenBundle(invalid); err == nil {
+		t.Error("Expected an error for a file outside of the archive")
+	}
+	empty := filepath.Join(tempDir, "empty.zip")
+	writeZip(t, empty, map[string]string{"README.md": "no contracts"})
+	if _, err := OpenBundle(empty); err == nil {
+		t.Error("Expected an error for a bundle without contracts")
+	}
+	if _, err := OpenBundle(filepath.Join(tempDir, "contracts.rar")); err == nil {
+		t.Error("Expected an error for a missing bundle")
+	}
+	sevenZip := filepath.Join(tempDir, "contracts.7z")
+	if err := os.WriteFile(sevenZip, []byte("7z"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenBundle(sevenZip); err == nil {
+		t.Error("Expected an error for an unsupported archive")
+	}
+}
+
+func TestBundleArg(t *testing.T) {
+	flags := pflag.NewFlagSet("twinroom", pflag.ContinueOnError)
+	flags.String("bundle", "", "")
+	flags.String("data", "", "")
+	flags.StringP("output", "o", "", "")
+	flags.Bool("dry-run", false, "")
+
+	tests := []struct {
+		args     []string
+		expected string
+		wantErr  bool
+	}{
+		{args: []string{"--bundle", "contracts.zip", "list"}, expected: "contracts.zip"},
+		{args: []string{"--bundle=contracts.zip", "list"}, expected: "contracts.zip"},
+		{args: []string{"--dry-run", "--bundle", "contracts.zip", "greet", "hello"}, expected: "contracts.zip"},
+		{args: []string{"--data", "{}", "--bundle", "contracts.zip", "list"}, expected: "contracts.zip"},
+		{args: []string{"list"}},
+		{args: []string{}},
+		// The value of a flag is not the bundle flag
+		{args: []string{"--data", "--bundle", "list"}},
+		{args: []string{"-o", "--bundle", "list"}},
+		{args: []string{"--data=x", "--bundle", "contracts.zip"}, expected: "contracts.zip"},
+		// The flags of the command and the arguments after -- are not looked up
+		{args: []string{"greet", "hello", "--bundle", "contracts.zip"}},
+		{args: []string{"--", "--bundle", "contracts.zip"}},
+		// A --bundle without value is an error
+		{args: []string{"--bundle"}, wantErr: true},
+		{args: []string{"--bundle="}, wantErr: true},
+		{args: []string{"--bundle", "--", "list"}, wantErr: true},
+		{args: []string{"--bundle", "--dry-run", "list"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		value, err := BundleArg(flags, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("BundleArg(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if value != tt.expected {
+			t.Errorf("BundleArg(%q) = %q, expected %q", tt.args, value, tt.expected)
+		}
+	}
+}
+
+func TestPositionalArgs(t *testing.T) {
+	flags := pflag.NewFlagSet("twinroom", pflag.ContinueOnError)
+	flags.String("data", "", "")
+	flags.StringP("output", "o", "", "")
+	flags.Bool("dry-run", false, "")
+
+	tests := []struct {
+		args     []string
+		expected []int
+	}{
+		{args: []string{"my contracts", "hello", "alice"}, expected: []int{0, 1, 2}},
+		{args: []string{"--data", "{}", "--dry-run", "folder", "-o", "yaml", "hello"}, expected: []int{3, 6}},
+		{args: []string{"--data={}", "folder", "-", "--", "--dry-run"}, expected: []int{1, 2, 4}},
+		{args: []string{"--data"}},
+	}
+	for _, tt := range tests {
+		if positionals := PositionalArgs(flags, tt.args); !reflect.DeepEqual(positionals, tt.expected) {
+			t.Errorf("PositionalArgs(%q) = %v, expected %v", tt.args, positionals, tt.expected)
+		}
+	}
+}
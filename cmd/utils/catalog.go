@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sources of the contracts listed by the list command
+const (
+	// ContractSourceEmbedded is a contract embedded in the binary at build time
+	ContractSourceEmbedded = "embedded"
+	// ContractSourceBundle is a contract of the bundle given at runtime with --bundle
+	ContractSourceBundle = "bundle"
+	// ContractSourceDisk is a contract of a folder on disk
+	ContractSourceDisk = "disk"
+)
+
+// ContractInfo describes a contract for the machine readable output of the list command
+type ContractInfo struct {
+	Path        string             `json:"path"`
+	Description string             `json:"description,omitempty"`
+	Arguments   []ArgumentMetadata `json:"arguments,omitempty"`
+	Options     []OptionMetadata   `json:"options,omitempty"`
+	Inputs      []ContractInput    `json:"inputs,omitempty"`
+	SideFiles   []string           `json:"side_files,omitempty"`
+	Embedded    bool               `json:"embedded"`
+	Source      string             `json:"source"` // One of the ContractSource constants
+	Hash        string             `json:"hash"`
+}
+
+// ContractInput is an input of the contract found by zenroom introspection
+type ContractInput struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Encoding string `json:"encoding"`
+	Zentype  string `json:"zentype"`
+}
+
+// DescribeContract collects the details of a contract read from source. The side files are looked
+// up in dir of fsys and the introspection inputs are always listed, even when metadata is present.
+func DescribeContract(fsys fs.FS, dir string, contractPath string, content string, source string) (ContractInfo, error) {
+	info := ContractInfo{
+		Path:     contractPath,
+		Embedded: source == ContractSourceEmbedded,
+		Source:   source,
+		Hash:     "sha256:" + ContractHash(content),
+	}
+	name := path.Base(contractPath)
+
+	if err := CheckSideFileFormats(fsys, dir, name); err != nil {
+		return info, err
+	}
+	metadataPath := ""
+	for _, side := range append([]string{"metadata"}, sideFiles...) {
+		sidePath, err := FindSideFile(fsys, path.Join(dir, name+"."+side))
+		if err != nil {
+			return info, err
+		}
+		if sidePath == "" {
+			continue
+		}
+		info.SideFiles = append(info.SideFiles, side)
+		if side == "metadata" {
+			metadataPath = sidePath
+		}
+	}
+	if metadataPath != "" {
+		metadataContent, err := ReadSideFile(fsys, metadataPath)
+		if err != nil {
+			return info, fmt.Errorf("failed to read metadata of %s: %w", contractPath, err)
+		}
+		var metadata CommandMetadata
+		if err := json.Unmarshal(metadataContent, &metadata); err != nil {
+			return info, fmt.Errorf("failed to decode metadata of %s: %w", contractPath, err)
+		}
+		info.Description = metadata.Description
+		info.Arguments = metadata.Arguments
+		info.Options = metadata.Options
+	}
+
+	// The inputs are not listed when slangroom-exec cannot introspect the contract
+	if introspectionData, err := IntrospectContract(content); err == nil && introspectionData != "" {
+		var introspection Introspection
+		if err := json.Unmarshal([]byte(introspectionData), &introspection); err != nil {
+			return info, fmt.Errorf("failed to parse introspection data of %s: %w", contractPath, err)
+		}
+		for _, codec := range introspection {
+			info.Inputs = append(info.Inputs, ContractInput{
+				Name:     codec.Name,
+				Type:     introspectionFlagType(codec),
+				Encoding: codec.Encoding,
+				Zentype:  codec.Zentype,
+			})
+		}
+		sort.Slice(info.Inputs, func(i, j int) bool { return info.Inputs[i].Name < info.Inputs[j].Name })
+	}
+	return info, nil
+}
+
+// ToJSON encodes a value as indented JSON, without escaping the HTML characters
+func ToJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// ToYAML encodes a value as YAML, using its JSON field names
+func ToYAML(value interface{}) ([]byte, error) {
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(jsonValue, &generic); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(generic)
+}
+
+// WriteContractsTable prints the contracts as a table for people
+func WriteContractsTable(w io.Writer, contracts []ContractInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "PATH\tSOURCE\tARGUMENTS\tOPTIONS\tINPUTS\tSIDE FILES\tHASH\tDESCRIPTION"); err != nil {
+		return err
+	}
+	for _, contract := range contracts {
+		options := make([]string, len(contract.Options))
+		for i, opt := range contract.Options {
+			options[i] = "--" + GetFlagName(opt.Name)
+		}
+		inputs := make([]string, len(contract.Inputs))
+		for i, input := range contract.Inputs {
+			inputs[i] = input.Name
+		}
+		_, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			contract.Path,
+			orDash(contract.Source),
+			orDash(strings.Join(GetArgumentNames(contract.Arguments), " ")),
+			orDash(strings.Join(options, " ")),
+			orDash(strings.Join(inputs, " ")),
+			orDash(strings.Join(contract.SideFiles, " ")),
+			strings.TrimPrefix(contract.Hash, "sha256:")[:12],
+			contract.Description,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// orDash returns a dash for empty table cells
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDescribeContract(t *testing.T) {
+	contract := `Given I have a 'string' named 'username'
+Then print the data`
+	fsys := fstest.MapFS{
+		"contracts/test/login.slang":         {Data: []byte(contract)},
+		"contracts/test/login.metadata.json": {Data: []byte(`{"description": "login", "arguments": [{"name": "<username>"}]}`)},
+		"contracts/test/login.keys.json":     {Data: []byte(`{}`)},
+	}
+
+	info, err := DescribeContract(fsys, "contracts/test", "test/login", contract, ContractSourceEmbedded)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info.Path != "test/login" || !info.Embedded || info.Description != "login" {
+		t.Errorf("Unexpected contract info %+v", info)
+	}
+	if info.Hash != "sha256:"+ContractHash(contract) {
+		t.Errorf("Expected the hash of the contract, got %s", info.Hash)
+	}
+	if strings.Join(info.SideFiles, ",") != "metadata,keys" {
+		t.Errorf("Expected metadata and keys side files, got %v", info.SideFiles)
+	}
+	if len(info.Arguments) != 1 || len(info.Inputs) != 1 || info.Inputs[0].Name != "username" || info.Inputs[0].Type != "string" {
+		t.Errorf("Expected the username argument and input, got %+v", info)
+	}
+
+	fsys["contracts/test/login.metadata.json"] = &fstest.MapFile{Data: []byte(`{"description": }`)}
+	if _, err := DescribeContract(fsys, "contracts/test", "test/login", contract, ContractSourceEmbedded); err == nil {
+		t.Error("Expected an error for invalid metadata")
+	}
+}
+
+func TestContractsOutput(t *testing.T) {
+	contracts := []ContractInfo{{
+		Path:      "test/login",
+		Arguments: []ArgumentMetadata{{Name: "<username>"}},
+		Options:   []OptionMetadata{{Name: "-n, --name <name>"}},
+		Embedded:  true,
+		Source:    ContractSourceEmbedded,
+		Hash:      "sha256:" + ContractHash("contract"),
+	}}
+
+	document, err := ToJSON(contracts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(document), `"name": "<username>"`) {
+		t.Errorf("Expected unescaped argument names, got %s", document)
+	}
+
+	document, err = ToYAML(contracts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(document), "path: test/login") {
+		t.Errorf("Expected YAML with JSON field names, got %s", document)
+	}
+
+	var table bytes.Buffer
+	if err := WriteContractsTable(&table, contracts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "PATH") {
+		t.Fatalf("Expected a header and a row, got %s", table.String())
+	}
+	for _, expected := range []string{"test/login", "embedded", "<username>", "--name"} {
+		if !strings.Contains(lines[1], expected) {
+			t.Errorf("Expected row to contain %s, got %s", expected, lines[1])
+		}
+	}
+}
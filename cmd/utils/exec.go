@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+)
+
+// ErrTimeout is returned by ExecContract when the contract does not end in time
+var ErrTimeout = errors.New("contract execution timed out")
+
+// ErrSlangroomMissing is returned by ExecContract when slangroom-exec is not installed
+var ErrSlangroomMissing = errors.New("slangroom-exec not found in PATH")
+
+// execBindings executes the contract with the bindings of slangroom-exec
+var execBindings = slangroom.Exec
+
+// slangroomProcess is the name of the process started by the bindings
+const slangroomProcess = "slangroom-exec"
+
+// ExecContract executes the contract with slangroom, a zero timeout waits for it to end.
+// Missing slangroom-exec and timeouts are reported as ErrSlangroomMissing and ErrTimeout.
+// The bindings cannot be cancelled, so at the timeout the slangroom-exec processes started by
+// this process are killed: the executions with a timeout must not run concurrently.
+func ExecContract(input slangroom.SlangroomInput, timeout time.Duration) (slangroom.SlangResult, error) {
+	if timeout <= 0 {
+		return classifyExecError(execBindings(input))
+	}
+	type execResult struct {
+		res slangroom.SlangResult
+		err error
+	}
+	done := make(chan execResult, 1)
+	go func() {
+		res, err := execBindings(input)
+		done <- execResult{res: res, err: err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		return classifyExecError(result.res, result.err)
+	case <-timer.C:
+		killChildren(slangroomProcess)
+		return slangroom.SlangResult{}, fmt.Errorf("%w after %s", ErrTimeout, timeout)
+	}
+}
+
+// killChildren kills the child processes of this process with the given name. The processes are
+// looked up in /proc, where it is missing they keep running until they end.
+func killChildren(name string) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return
+	}
+	parent := strconv.Itoa(os.Getpid())
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat")) // #nosec G304 -- file of the kernel
+		if err != nil {
+			continue
+		}
+		// The name is between parentheses, it is followed by the state and by the parent pid
+		start, end := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+		if start < 0 || end < start {
+			continue
+		}
+		fields := strings.Fields(string(stat[end+1:]))
+		if len(fields) < 2 || fields[1] != parent || string(stat[start+1:end]) != name {
+			continue
+		}
+		if process, err := os.FindProcess(pid); err == nil {
+			_ = process.Kill()
+		}
+	}
+}
+
+// classifyExecError wraps the errors caused by a missing slangroom-exec in ErrSlangroomMissing
+func classifyExecError(res slangroom.SlangResult, err error) (slangroom.SlangResult, error) {
+	if err != nil && (errors.Is(err, exec.ErrNotFound) || strings.Contains(err.Error(), "executable file not found")) {
+		return res, fmt.Errorf("%w: %v", ErrSlangroomMissing, err)
+	}
+	return res, err
+}
+
+// logLine is a zenroom log line as written by WriteLogs
+type logLine struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// logLevel returns the level of a zenroom log line from its prefix, like [!] for errors
+func logLevel(line string) string {
+	switch {
+	case strings.HasPrefix(line, "[!]"):
+		return "error"
+	case strings.HasPrefix(line, "[W]"):
+		return "warning"
+	case strings.HasPrefix(line, "[D]"):
+		return "debug"
+	default:
+		return "info"
+	}
+}
+
+// WriteLogs writes the zenroom logs as JSON lines, one object with level and message for
+// each non empty log line, so that they can be parsed by other tools. The heap and the trace
+// sections are left out, ExecutionErrorLine decodes them.
+func WriteLogs(w io.Writer, logs string) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if _, _, ok := j64Section(strings.TrimSpace(line)); ok || strings.TrimSpace(line) == "" {
+			continue
+		}
+		level := logLevel(line)
+		message := line
+		if level != "info" {
+			message = strings.TrimSpace(line[3:])
+		}
+		if err := encoder.Encode(logLine{Level: level, Message: message}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+)
+
+func TestWriteLogs(t *testing.T) {
+	logs := "[*] Zenroom starting\n[W] deprecated statement\n\n[!] Cannot find 'name' anywhere\r\n[D] heap dump\n[!] J64 TRACE: WyIrMSJd\n"
+	var buf bytes.Buffer
+	if err := WriteLogs(&buf, logs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"level":"info","message":"[*] Zenroom starting"}
+{"level":"warning","message":"deprecated statement"}
+{"level":"error","message":"Cannot find 'name' anywhere"}
+{"level":"debug","message":"heap dump"}
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestClassifyExecError(t *testing.T) {
+	notFound := &exec.Error{Name: "slangroom-exec", Err: exec.ErrNotFound}
+	if _, err := classifyExecError(slangroom.SlangResult{}, fmt.Errorf("failed to run: %w", notFound)); !errors.Is(err, ErrSlangroomMissing) {
+		t.Errorf("Expected ErrSlangroomMissing, got %v", err)
+	}
+	failure := errors.New("exit status 1")
+	if _, err := classifyExecError(slangroom.SlangResult{Logs: "[!] error"}, failure); err != failure {
+		t.Errorf("Expected the execution error unchanged, got %v", err)
+	}
+	if _, err := classifyExecError(slangroom.SlangResult{}, nil); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestExecContractTimeout(t *testing.T) {
+	// A fake slangroom-exec that never ends, it writes its pid to find it after the timeout
+	tempDir := t.TempDir()
+	pidFile := filepath.Join(tempDir, "pid")
+	script := "#!/bin/sh\necho $$ > " + pidFile + "\nwhile :; do sleep 1; done\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "slangroom-exec"), []byte(script), 0700); err != nil { // #nosec G306 -- executable script
+		t.Fatal(err)
+	}
+	previous := execBindings
+	execBindings = func(slangroom.SlangroomInput) (slangroom.SlangResult, error) {
+		cmd := exec.Command(filepath.Join(tempDir, "slangroom-exec")) // #nosec G204 -- script of the test
+		err := cmd.Run()
+		return slangroom.SlangResult{}, err
+	}
+	t.Cleanup(func() { execBindings = previous })
+
+	start := time.Now()
+	if _, err := ExecContract(slangroom.SlangroomInput{Contract: "Given nothing"}, 200*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Expected ErrTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the execution to stop at the timeout, took %s", elapsed)
+	}
+	content, err := os.ReadFile(pidFile) // #nosec G304 -- file of the test
+	if err != nil {
+		t.Fatalf("Expected the pid of the fake slangroom-exec: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The killed process is gone once the bindings have waited for it
+	err = syscall.Kill(pid, 0)
+	for deadline := time.Now().Add(5 * time.Second); err == nil && time.Now().Before(deadline); err = syscall.Kill(pid, 0) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !errors.Is(err, syscall.ESRCH) {
+		t.Errorf("Expected the process %d to be killed, got %v", pid, err)
+	}
+
+	execBindings = func(slangroom.SlangroomInput) (slangroom.SlangResult, error) {
+		return slangroom.SlangResult{}, &exec.Error{Name: "slangroom-exec", Err: exec.ErrNotFound}
+	}
+	if _, err := ExecContract(slangroom.SlangroomInput{}, time.Second); !errors.Is(err, ErrSlangroomMissing) {
+		t.Errorf("Expected ErrSlangroomMissing, got %v", err)
+	}
+}
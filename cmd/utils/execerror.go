@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ExecutionError is a failed contract execution, with the details parsed from the zenroom logs
+type ExecutionError struct {
+	// Err is the error returned by slangroom-exec
+	Err error `json:"-"`
+	// Message is the zenroom error message
+	Message string `json:"message"`
+	// Line is the line of the failing statement in the contract, zero if unknown
+	Line int `json:"line,omitempty"`
+	// Statement is the failing statement of the contract
+	Statement string `json:"statement,omitempty"`
+	// Heap is the zenroom heap at the time of the error, decoded from the J64 HEAP section
+	Heap interface{} `json:"heap,omitempty"`
+	// Trace is the zenroom execution trace, decoded from the J64 TRACE section
+	Trace []string `json:"trace,omitempty"`
+	// Logs are the raw zenroom logs
+	Logs string `json:"-"`
+}
+
+func (e *ExecutionError) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Statement != "" {
+		fmt.Fprintf(&b, "%s: ", e.Statement)
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+func (e *ExecutionError) Unwrap() error {
+	return e.Err
+}
+
+// ExecutionErrorLine returns the failed execution as a JSON line like the ones of WriteLogs, with
+// the error level, the concise message and the details of the error. The heap and the trace are
+// part of the details only when verbose is true.
+func ExecutionErrorLine(execErr *ExecutionError, verbose bool) ([]byte, error) {
+	details := *execErr
+	if !verbose {
+		details.Heap = nil
+		details.Trace = nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(struct {
+		Level   string          `json:"level"`
+		Message string          `json:"message"`
+		Error   *ExecutionError `json:"error"`
+	}{Level: "error", Message: execErr.Error(), Error: &details})
+	return buf.Bytes(), err
+}
+
+var (
+	// zencodeLineRegexp matches the line reported by zenroom, like "Zencode line 3: When I ..."
+	zencodeLineRegexp = regexp.MustCompile(`(?i)zencode line (\d+)(?::\s*(.+))?`)
+	// luaPrefixRegexp matches the lua source prefix of zenroom errors, like [string "ZEN"]:42:
+	luaPrefixRegexp = regexp.MustCompile(`^\[string "[^"]*"\]:\d+:\s*`)
+	// genericErrorRegexp matches the zenroom error lines that carry no information of their own
+	genericErrorRegexp = regexp.MustCompile(`(?i)^(execution aborted|zencode runtime error|zenroom error|error at zencode line|error in zencode line)`)
+)
+
+// ParseExecutionError pulls the failing statement, its line, the error message and the heap
+// and trace sections out of the logs of a failed execution of contract.
+func ParseExecutionError(contract, logs string, err error) *ExecutionError {
+	execErr := &ExecutionError{Err: err, Logs: logs}
+	// Lines that only locate the error are used as message when nothing better is found
+	var messages, locations []string
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimSpace(strings.TrimRight(line, "\r"))
+		if line == "" {
+			continue
+		}
+		if section, value, ok := j64Section(line); ok {
+			switch section {
+			case "HEAP":
+				execErr.Heap = decodeHeap(value)
+			case "TRACE":
+				execErr.Trace = decodeTrace(value)
+			}
+			continue
+		}
+		if logLevel(line) != "error" {
+			continue
+		}
+		message := luaPrefixRegexp.ReplaceAllString(strings.TrimSpace(line[3:]), "")
+		if match := zencodeLineRegexp.FindStringSubmatch(message); match != nil {
+			if execErr.Line == 0 {
+				execErr.Line, _ = strconv.Atoi(match[1])
+				execErr.Statement = strings.TrimSpace(match[2])
+			}
+			if strings.HasPrefix(strings.ToLower(message), "zencode line") {
+				locations = append(locations, message)
+				continue
+			}
+		}
+		if message != "" && !genericErrorRegexp.MatchString(message) {
+			messages = append(messages, message)
+		}
+	}
+
+	// The statement as written in the contract is more accurate than the one in the logs
+	if lines := strings.Split(contract, "\n"); execErr.Line > 0 && execErr.Line <= len(lines) {
+		if statement := strings.TrimSpace(lines[execErr.Line-1]); statement != "" {
+			execErr.Statement = statement
+		}
+	}
+
+	switch {
+	case len(messages) > 0:
+		execErr.Message = messages[0]
+	case len(locations) > 0:
+		execErr.Message = locations[0]
+	case err != nil:
+		execErr.Message = err.Error()
+	default:
+		execErr.Message = "contract execution failed"
+	}
+	return execErr
+}
+
+// j64Section returns the name and the value of a base64 section of the logs, like J64 HEAP: ...
+func j64Section(line string) (string, string, bool) {
+	line = strings.TrimLeft(line, "[]!WD* .")
+	if !strings.HasPrefix(line, "J64 ") {
+		return "", "", false
+	}
+	name, value, ok := strings.Cut(strings.TrimPrefix(line, "J64 "), ":")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(name), strings.TrimSpace(value), true
+}
+
+// decodeHeap decodes the heap section, keeping the base64 value if it is not JSON
+func decodeHeap(value string) interface{} {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return value
+	}
+	var heap interface{}
+	if err := json.Unmarshal(decoded, &heap); err != nil {
+		return string(decoded)
+	}
+	return heap
+}
+
+// decodeTrace decodes the trace section, a JSON array of strings or plain text lines
+func decodeTrace(value string) []string {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return []string{value}
+	}
+	var trace []string
+	if err := json.Unmarshal(decoded, &trace); err == nil {
+		return trace
+	}
+	for _, line := range strings.Split(string(decoded), "\n") {
+		if strings.TrimSpace(line) != "" {
+			trace = append(trace, line)
+		}
+	}
+	return trace
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseExecutionError(t *testing.T) {
+	contract := "Given I have a 'string' named 'name'\nWhen I create the random 'seed'\nThen print the 'name'\n"
+	heap := base64.StdEncoding.EncodeToString([]byte(`{"GIVEN_data":{"name":"alice"}}`))
+	trace := base64.StdEncoding.EncodeToString([]byte(`["+1  Given I have a 'string' named 'name'","+2  When I create the random 'seed'"]`))
+	logs := "[*] Zenroom starting\n" +
+		"[!] [string \"ZEN:parse\"]:42: Zencode line 2: When I create the random seed\n" +
+		"[!] Cannot create 'seed': random generator not seeded\n" +
+		"[!] Execution aborted with errors.\n" +
+		"[!] J64 HEAP: " + heap + "\n" +
+		"[!] J64 TRACE: " + trace + "\n"
+	failure := errors.New("exit status 1")
+
+	execErr := ParseExecutionError(contract, logs, failure)
+	if execErr.Line != 2 {
+		t.Errorf("Expected line 2, got %d", execErr.Line)
+	}
+	if execErr.Statement != "When I create the random 'seed'" {
+		t.Errorf("Expected the statement from the contract, got %q", execErr.Statement)
+	}
+	if execErr.Message != "Cannot create 'seed': random generator not seeded" {
+		t.Errorf("Unexpected message %q", execErr.Message)
+	}
+	expectedHeap := map[string]interface{}{"GIVEN_data": map[string]interface{}{"name": "alice"}}
+	if !reflect.DeepEqual(execErr.Heap, expectedHeap) {
+		t.Errorf("Expected heap %v, got %v", expectedHeap, execErr.Heap)
+	}
+	if len(execErr.Trace) != 2 || execErr.Trace[1] != "+2  When I create the random 'seed'" {
+		t.Errorf("Unexpected trace %v", execErr.Trace)
+	}
+	if !errors.Is(execErr, failure) {
+		t.Errorf("Expected the execution error to wrap the slangroom one")
+	}
+	expected := "line 2: When I create the random 'seed': Cannot create 'seed': random generator not seeded"
+	if execErr.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, execErr.Error())
+	}
+}
+
+func TestParseExecutionErrorWithoutLogs(t *testing.T) {
+	execErr := ParseExecutionError("", "", ErrTimeout)
+	if execErr.Message != ErrTimeout.Error() || execErr.Line != 0 || execErr.Statement != "" {
+		t.Errorf("Expected only the timeout message, got %+v", execErr)
+	}
+	if !errors.Is(execErr, ErrTimeout) {
+		t.Errorf("Expected the execution error to wrap ErrTimeout")
+	}
+
+	execErr = ParseExecutionError("", "[!] Zencode line 7: Then print 'x'\n[!] Execution aborted\n", nil)
+	if execErr.Line != 7 || execErr.Statement != "Then print 'x'" {
+		t.Errorf("Expected the statement from the logs, got %+v", execErr)
+	}
+	if execErr.Message != "Zencode line 7: Then print 'x'" {
+		t.Errorf("Unexpected message %q", execErr.Message)
+	}
+}
+
+func TestExecutionErrorLine(t *testing.T) {
+	execErr := &ExecutionError{Message: "Cannot find 'name' anywhere", Line: 1, Statement: "Given I have a 'string' named 'name'", Trace: []string{"+1  Given I have a 'string' named 'name'"}}
+	line, err := ExecutionErrorLine(execErr, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"level":"error","message":"line 1: Given I have a 'string' named 'name': Cannot find 'name' anywhere","error":{"message":"Cannot find 'name' anywhere","line":1,"statement":"Given I have a 'string' named 'name'"}}` + "\n"
+	if string(line) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, line)
+	}
+	if line, err = ExecutionErrorLine(execErr, true); err != nil || !strings.Contains(string(line), `"trace":["+1  Given I have a 'string' named 'name'"]`) {
+		t.Errorf("Expected the trace with verbose, got %s %v", line, err)
+	}
+	if len(execErr.Trace) != 1 {
+		t.Errorf("Expected the execution error unchanged, got %+v", execErr)
+	}
+}
@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	jsschema "github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/spf13/cobra"
+)
+
+// jsonFlag is a flag holding a JSON document, used for object options
+type jsonFlag struct {
+	raw   string
+	value interface{}
+}
+
+func (f *jsonFlag) String() string {
+	return f.raw
+}
+
+func (f *jsonFlag) Set(raw string) error {
+	content := []byte(raw)
+	// @path reads the JSON document from a file
+	if strings.HasPrefix(raw, "@") {
+		var err error
+		content, err = os.ReadFile(strings.TrimPrefix(raw, "@"))
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+	var value interface{}
+	if err := json.Unmarshal(content, &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	f.raw = raw
+	f.value = value
+	return nil
+}
+
+func (f *jsonFlag) Type() string {
+	return "json"
+}
+
+// addTypedFlag registers a flag whose pflag type matches the JSON Schema type of the option
+func addTypedFlag(cmd *cobra.Command, name, shorthand, flagType, def, usage string) error {
+	flags := cmd.Flags()
+	switch flagType {
+	case "integer":
+		var value int
+		if def != "" {
+			parsed, err := strconv.Atoi(def)
+			if err != nil {
+				return fmt.Errorf("invalid default %q for integer flag %s", def, name)
+			}
+			value = parsed
+		}
+		flags.IntP(name, shorthand, value, usage)
+	case "number":
+		var value float64
+		if def != "" {
+			parsed, err := strconv.ParseFloat(def, 64)
+			if err != nil {
+				return fmt.Errorf("invalid default %q for number flag %s", def, name)
+			}
+			value = parsed
+		}
+		flags.Float64P(name, shorthand, value, usage)
+	case "boolean":
+		var value bool
+		if def != "" {
+			parsed, err := strconv.ParseBool(def)
+			if err != nil {
+				return fmt.Errorf("invalid default %q for boolean flag %s", def, name)
+			}
+			value = parsed
+		}
+		flags.BoolP(name, shorthand, value, usage)
+	case "array":
+		var value []string
+		if def != "" {
+			value = strings.Split(def, ",")
+		}
+		flags.StringSliceP(name, shorthand, value, usage)
+	case "object":
+		value := &jsonFlag{}
+		if def != "" {
+			if err := value.Set(def); err != nil {
+				return fmt.Errorf("invalid default for object flag %s: %w", name, err)
+			}
+		}
+		flags.VarP(value, name, shorthand, usage)
+	default:
+		flags.StringP(name, shorthand, def, usage)
+	}
+	return nil
+}
+
+// envSourceAnnotation marks the flags set from an environment variable, see FlagSource
+const envSourceAnnotation = "twinroom_env_source"
+
+// setFlagFromEnv sets a flag to the value of an environment variable and records the variable
+func setFlagFromEnv(cmd *cobra.Command, flag, envVar, value string) error {
+	if err := cmd.Flags().Set(flag, value); err != nil {
+		return err
+	}
+	return cmd.Flags().SetAnnotation(flag, envSourceAnnotation, []string{envVar})
+}
+
+// typedFlagValue returns the value of a non string flag with its JSON type. The environment
+// variables of the flag are used when it is not set on the command line. The boolean is
+// false when the flag has neither been set nor has a default value.
+func typedFlagValue(cmd *cobra.Command, flag string, content FlagData) (interface{}, bool, error) {
+	f := cmd.Flags().Lookup(flag)
+	if f == nil {
+		return nil, false, fmt.Errorf("unknown flag %s", flag)
+	}
+	if !f.Changed {
+		for _, envVar := range content.Env {
+			if envValue := os.Getenv(envVar); envValue != "" {
+				if err := setFlagFromEnv(cmd, flag, envVar, envValue); err != nil {
+					return nil, false, fmt.Errorf("invalid value from environment variable %s for flag %s: %w", envVar, flag, err)
+				}
+				break
+			}
+		}
+	}
+	if !f.Changed && !content.HasDefault {
+		return nil, false, nil
+	}
+
+	switch content.Type {
+	case "integer":
+		value, err := cmd.Flags().GetInt(flag)
+		return value, true, err
+	case "number":
+		value, err := cmd.Flags().GetFloat64(flag)
+		return value, true, err
+	case "boolean":
+		value, err := cmd.Flags().GetBool(flag)
+		return value, true, err
+	case "array":
+		values, err := cmd.Flags().GetStringSlice(flag)
+		if err != nil {
+			return nil, false, err
+		}
+		items, err := parseItems(content.ItemsType, values)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid value for flag %s: %w", flag, err)
+		}
+		return items, true, nil
+	case "object":
+		return f.Value.(*jsonFlag).value, true, nil
+	default:
+		value, err := cmd.Flags().GetString(flag)
+		return value, true, err
+	}
+}
+
+// ParseTypedValue converts a raw command line value into the JSON type named by typeStr,
+// as used by the type key of metadata arguments and options.
+func ParseTypedValue(typeStr string, raw string) (interface{}, error) {
+	switch jsonSchemaType(typeStr) {
+	case "integer":
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		return value, nil
+	case "number":
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return value, nil
+	case "boolean":
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return value, nil
+	case "array", "object":
+		var value interface{}
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, fmt.Errorf("%q is not valid JSON: %w", raw, err)
+		}
+		return value, nil
+	default:
+		return raw, nil
+	}
+}
+
+// parseItems converts each raw value into the JSON type of the array elements
+func parseItems(itemsType string, values []string) ([]interface{}, error) {
+	items := make([]interface{}, len(values))
+	for i, v := range values {
+		item, err := ParseTypedValue(itemsType, v)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// itemsGoType returns the type name of the array elements for MapTypeToGoType, or def
+// if the metadata does not declare it.
+func itemsGoType(items map[string]interface{}, def string) string {
+	if typeStr, ok := items["type"].(string); ok {
+		return typeStr
+	}
+	return def
+}
+
+// addPropertyFlags registers a --option.property flag for each property declared by an
+// object option, recursing into nested objects.
+func addPropertyFlags(cmd *cobra.Command, option string, path []string, properties map[string]interface{}, flagContents map[string]FlagData) error {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property, ok := properties[name].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid property %s for flag %s", name, option)
+		}
+		propertyPath := append(append([]string{}, path...), name)
+		flag := option + "." + strings.Join(propertyPath, ".")
+		typeStr, _ := property["type"].(string)
+		flagType := jsonSchemaType(typeStr)
+		if nested, ok := property["properties"].(map[string]interface{}); ok && flagType == "object" {
+			if err := addPropertyFlags(cmd, option, propertyPath, nested, flagContents); err != nil {
+				return err
+			}
+			continue
+		}
+		if flagType == "string" {
+			flagType = ""
+		}
+		usage, _ := property["description"].(string)
+		if usage == "" {
+			usage = fmt.Sprintf("The %s property of --%s", strings.Join(propertyPath, "."), option)
+		}
+		if err := addTypedFlag(cmd, flag, "", flagType, "", usage); err != nil {
+			return err
+		}
+		items, _ := property["items"].(map[string]interface{})
+		flagContents[flag] = FlagData{
+			Type:      flagType,
+			ItemsType: ItemsType(items),
+			Path:      append([]string{option}, propertyPath...),
+		}
+	}
+	return nil
+}
+
+// storeFlagValue writes the value of a flag in the contract data, nesting the values
+// of --option.property flags inside the object of their option.
+func storeFlagValue(argContents map[string]interface{}, flag string, content FlagData, value interface{}) error {
+	if len(content.Path) == 0 {
+		argContents[flag] = value
+		return nil
+	}
+	current := argContents
+	for _, key := range content.Path[:len(content.Path)-1] {
+		next, exists := current[key]
+		if !exists || next == nil || next == "" {
+			next = make(map[string]interface{})
+			current[key] = next
+		}
+		nested, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot set flag %s: %s is not an object", flag, key)
+		}
+		current = nested
+	}
+	current[content.Path[len(content.Path)-1]] = value
+	return nil
+}
+
+// compileProperties compiles the schema of an object option from the properties declared in
+// metadata, once for all the validations of the option
+func compileProperties(flag string, properties map[string]interface{}) (*jsschema.Schema, error) {
+	schema, err := CompileJSONSchema(map[string]interface{}{"type": "object", "properties": properties})
+	if err != nil {
+		return nil, fmt.Errorf("invalid properties for flag %s: %w", flag, err)
+	}
+	return schema, nil
+}
+
+// introspectionFlagType returns the JSON type of an input found by zenroom introspection
+func introspectionFlagType(info codec) string {
+	return jsonSchemaType(ZentypeToType(info))
+}
+
+// introspectionItemsType returns the JSON type of the elements of an array input found by zenroom
+// introspection, the encoding of an array is the one of its elements
+func introspectionItemsType(info codec) string {
+	if info.Zentype != "a" {
+		return ""
+	}
+	return jsonSchemaType(info.Encoding)
+}
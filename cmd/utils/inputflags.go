@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+)
+
+// InputFlags are the values of the --data, --keys, --extra, --context and --conf flags, each one
+// is inline JSON, @path to read it from a file or - to read it from the standard input.
+type InputFlags struct {
+	Data    string
+	Keys    string
+	Extra   string
+	Context string
+	Conf    string
+	// Merge describes how the flags are merged with the side files
+	Merge MergeOptions
+}
+
+// ReadInputFlag returns the JSON object given to an input flag, reading it from a file or from
+// stdin when needed.
+func ReadInputFlag(name string, value string, stdin io.Reader) (string, error) {
+	content := []byte(value)
+	var err error
+	switch {
+	case value == "-":
+		if content, err = io.ReadAll(stdin); err != nil {
+			return "", fmt.Errorf("error reading value for flag %s from stdin: %w", name, err)
+		}
+	case strings.HasPrefix(value, "@"):
+		if content, err = os.ReadFile(value[1:]); err != nil {
+			return "", fmt.Errorf("failed to read file at path %s: %w", value[1:], err)
+		}
+	}
+	if err := validateJSON(content); err != nil {
+		return "", fmt.Errorf("invalid JSON in %s: %w", inputFlagSource(name, value), err)
+	}
+	return string(content), nil
+}
+
+// inputFlagSource describes where the value of an input flag comes from
+func inputFlagSource(name string, value string) string {
+	switch {
+	case value == "-":
+		return fmt.Sprintf("stdin (--%s)", name)
+	case strings.HasPrefix(value, "@"):
+		return fmt.Sprintf("file %s (--%s)", value[1:], name)
+	default:
+		return "flag --" + name
+	}
+}
+
+// InputLayer is one of the layers merged into the input of a contract, like the side files or
+// the input flags, with the source of each of its parts
+type InputLayer struct {
+	Input   slangroom.SlangroomInput
+	Sources InputSources
+}
+
+// ApplyInputFlags merges the input flags over the side files already loaded in the input, with
+// the merge options of the flags, and returns the layer of the flags. The sources, if not nil,
+// are updated with the flags that were used.
+func ApplyInputFlags(input *slangroom.SlangroomInput, flags InputFlags, stdin io.Reader, sources *InputSources) (InputLayer, error) {
+	var layer InputLayer
+	if err := flags.Merge.Validate(); err != nil {
+		return layer, err
+	}
+
+	var dummy InputSources
+	if sources == nil {
+		sources = &dummy
+	}
+	fields := []struct {
+		name        string
+		value       string
+		target      *string
+		source      *string
+		layer       *string
+		layerSource *string
+	}{
+		{"data", flags.Data, &input.Data, &sources.DataFile, &layer.Input.Data, &layer.Sources.DataFile},
+		{"keys", flags.Keys, &input.Keys, &sources.Keys, &layer.Input.Keys, &layer.Sources.Keys},
+		{"extra", flags.Extra, &input.Extra, &sources.Extra, &layer.Input.Extra, &layer.Sources.Extra},
+		{"context", flags.Context, &input.Context, &sources.Context, &layer.Input.Context, &layer.Sources.Context},
+		{"conf", flags.Conf, &input.Conf, &sources.Conf, &layer.Input.Conf, &layer.Sources.Conf},
+	}
+
+	stdinFlag := ""
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		if field.value == "-" {
+			if stdinFlag != "" {
+				return layer, fmt.Errorf("cannot read both --%s and --%s from stdin", stdinFlag, field.name)
+			}
+			stdinFlag = field.name
+		}
+		content, err := ReadInputFlag(field.name, field.value, stdin)
+		if err != nil {
+			return layer, err
+		}
+		base := *field.target
+		merged, err := Merge(base, content, flags.Merge)
+		if err != nil {
+			return layer, fmt.Errorf("failed to merge --%s: %w", field.name, err)
+		}
+		*field.target = merged
+
+		source := inputFlagSource(field.name, field.value)
+		*field.layer = content
+		*field.layerSource = source
+		if field.name == "data" {
+			recordDataFlagSources(sources, base, content, flags.Merge.Mode, source)
+			continue
+		}
+		if *field.source != "" {
+			source = *field.source + ", " + source
+		}
+		*field.source = source
+	}
+	return layer, nil
+}
+
+// MergeDiagnostics merges the parts of the layers like the input of the contract is merged and
+// reports, for each part, which layer won each value.
+func MergeDiagnostics(layers []InputLayer, opts MergeOptions) (map[string][]MergeDiagnostic, error) {
+	diagnostics := make(map[string][]MergeDiagnostic)
+	parts := []struct {
+		name    string
+		content func(InputLayer) (string, string)
+	}{
+		{"data", func(l InputLayer) (string, string) { return l.Input.Data, l.Sources.DataFile }},
+		{"keys", func(l InputLayer) (string, string) { return l.Input.Keys, l.Sources.Keys }},
+		{"extra", func(l InputLayer) (string, string) { return l.Input.Extra, l.Sources.Extra }},
+		{"context", func(l InputLayer) (string, string) { return l.Input.Context, l.Sources.Context }},
+		{"conf", func(l InputLayer) (string, string) { return l.Input.Conf, l.Sources.Conf }},
+	}
+	for _, part := range parts {
+		var docs []MergeSource
+		for _, layer := range layers {
+			content, source := part.content(layer)
+			docs = append(docs, MergeSource{Name: source, Content: content})
+		}
+		_, partDiagnostics, err := MergeDocuments(docs, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge %s: %w", part.name, err)
+		}
+		if len(partDiagnostics) > 0 {
+			diagnostics[part.name] = partDiagnostics
+		}
+	}
+	return diagnostics, nil
+}
+
+// recordDataFlagSources records the --data flag as source of the top level fields of its data,
+// a field deeply merged with the data file keeps both sources.
+func recordDataFlagSources(sources *InputSources, base string, content string, mode string, source string) {
+	var baseFields, fields map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &fields); err != nil {
+		return
+	}
+	if base != "" {
+		_ = json.Unmarshal([]byte(base), &baseFields)
+	}
+	if sources.Data == nil {
+		sources.Data = make(map[string]string)
+	}
+	for key, value := range fields {
+		_, baseIsMap := baseFields[key].(map[string]interface{})
+		_, valueIsMap := value.(map[string]interface{})
+		if mode != MergeShallow && baseIsMap && valueIsMap && sources.DataFile != "" {
+			sources.Data[key] = sources.DataFile + ", " + source
+			continue
+		}
+		sources.Data[key] = source
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+)
+
+func TestReadInputFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(`{"keyring": {}}`), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	for name, value := range map[string]string{
+		"Inline JSON": `{"keyring": {}}`,
+		"File":        "@" + path,
+		"Stdin":       "-",
+	} {
+		t.Run(name, func(t *testing.T) {
+			content, err := ReadInputFlag("keys", value, strings.NewReader(`{"keyring": {}}`))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if content != `{"keyring": {}}` {
+				t.Errorf("Unexpected content %s", content)
+			}
+		})
+	}
+
+	if _, err := ReadInputFlag("keys", "@"+filepath.Join(t.TempDir(), "missing.json"), nil); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+	if _, err := ReadInputFlag("keys", `{"keyring"`, nil); err == nil || !strings.Contains(err.Error(), "flag --keys") {
+		t.Errorf("Expected an invalid JSON error naming the flag, got %v", err)
+	}
+}
+
+func TestApplyInputFlags(t *testing.T) {
+	input := slangroom.SlangroomInput{
+		Data: `{"user": {"name": "a", "role": "b"}, "country": "IT"}`,
+		Keys: `{"keyring": {"ecdh": "x"}}`,
+	}
+	sources := InputSources{DataFile: "test.data.json", Keys: "test.keys.json", Data: make(map[string]string)}
+	flags := InputFlags{
+		Data:  `{"user": {"name": "c"}, "drink": "small"}`,
+		Keys:  "-",
+		Extra: `{"more": true}`,
+	}
+	layer, err := ApplyInputFlags(&input, flags, strings.NewReader(`{"keyring": {"eddsa": "y"}}`), &sources)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := slangroom.SlangroomInput{
+		Data:  `{"country":"IT","drink":"small","user":{"name":"c","role":"b"}}`,
+		Keys:  `{"keyring":{"ecdh":"x","eddsa":"y"}}`,
+		Extra: `{"more":true}`,
+	}
+	if input != expected {
+		t.Errorf("Expected %+v, got %+v", expected, input)
+	}
+	if sources.Data["user"] != "test.data.json, flag --data" || sources.Data["drink"] != "flag --data" {
+		t.Errorf("Unexpected data sources %v", sources.Data)
+	}
+	if sources.Keys != "test.keys.json, stdin (--keys)" || sources.Extra != "flag --extra" {
+		t.Errorf("Unexpected sources %+v", sources)
+	}
+
+	if layer.Input.Keys != `{"keyring": {"eddsa": "y"}}` || layer.Sources.Keys != "stdin (--keys)" || layer.Input.Data != flags.Data {
+		t.Errorf("Unexpected layer of the flags %+v", layer)
+	}
+
+	flags = InputFlags{Data: `{"user": {"name": "c"}}`, Merge: MergeOptions{Mode: MergeShallow}}
+	input = slangroom.SlangroomInput{Data: `{"user": {"name": "a", "role": "b"}}`}
+	if _, err := ApplyInputFlags(&input, flags, nil, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if input.Data != `{"user":{"name":"c"}}` {
+		t.Errorf("Expected a shallow merge, got %s", input.Data)
+	}
+
+	flags = InputFlags{Data: "-", Keys: "-"}
+	if _, err := ApplyInputFlags(&input, flags, strings.NewReader(`{}`), nil); err == nil {
+		t.Errorf("Expected an error reading two flags from stdin")
+	}
+}
+
+func TestMergeDiagnostics(t *testing.T) {
+	layers := []InputLayer{
+		{
+			Input:   slangroom.SlangroomInput{Data: `{"user": {"name": "a"}}`, Keys: `{"k": 1}`},
+			Sources: InputSources{DataFile: "test.data.json", Keys: "test.keys.json"},
+		},
+		{
+			Input:   slangroom.SlangroomInput{Data: `{"user": {"role": "b"}}`},
+			Sources: InputSources{DataFile: "flag --data"},
+		},
+	}
+	diagnostics, err := MergeDiagnostics(layers, MergeOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string][]MergeDiagnostic{
+		"data": {{Path: "/user/name", Source: "test.data.json"}, {Path: "/user/role", Source: "flag --data"}},
+		"keys": {{Path: "/k", Source: "test.keys.json"}},
+	}
+	if !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("Expected %v, got %v", expected, diagnostics)
+	}
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+	"github.com/spf13/cobra"
+)
+
+// Redacted replaces the value of secret fields when an input is inspected
+const Redacted = "[REDACTED]"
+
+// secretNameRegexp matches the data fields that are redacted when an input is inspected
+var secretNameRegexp = regexp.MustCompile(`(?i)(password|passphrase|secret|token|private|seed|mnemonic)`)
+
+// IsSecretName reports whether a data field is considered secret by its name
+func IsSecretName(name string) bool {
+	return secretNameRegexp.MatchString(name)
+}
+
+// InputSources records where each part of a SlangroomInput comes from, the sources of the
+// data are kept for each of its top level fields.
+type InputSources struct {
+	Contract string
+	DataFile string
+	Data     map[string]string
+	Keys     string
+	Extra    string
+	Context  string
+	Conf     string
+}
+
+// FlagSource describes where the value of a flag comes from: the command line, a file, the stdin,
+// an environment variable or its default. It returns an empty string if the flag has no value.
+func FlagSource(cmd *cobra.Command, flag string, content FlagData) string {
+	f := cmd.Flags().Lookup(flag)
+	if f == nil {
+		return ""
+	}
+	if content.File[0] && f.Value.String() != "" {
+		if f.Value.String() == "-" {
+			return fmt.Sprintf("stdin (--%s)", flag)
+		}
+		return fmt.Sprintf("file %s (--%s)", f.Value.String(), flag)
+	}
+	if f.Changed {
+		if envVar, ok := f.Annotations[envSourceAnnotation]; ok {
+			return fmt.Sprintf("environment variable %s (--%s)", envVar[0], flag)
+		}
+		return fmt.Sprintf("flag --%s", flag)
+	}
+	for _, envVar := range content.Env {
+		if os.Getenv(envVar) != "" {
+			return fmt.Sprintf("environment variable %s (--%s)", envVar, flag)
+		}
+	}
+	if content.HasDefault {
+		return fmt.Sprintf("default of --%s", flag)
+	}
+	return ""
+}
+
+// DataSources records the source of each top level field of the data sent to the contract. The
+// fields of baseData come from the data file of the contract, unless the --data flag already
+// recorded them, the ones of flagData from a file flag, and all of them are overwritten by the
+// arguments and the flags, like in the CLI.
+func DataSources(cmd *cobra.Command, metadata *CommandMetadata, flagContents map[string]FlagData, args []string, baseData string, flagData string, sources *InputSources) {
+	flags := make([]string, 0, len(flagContents))
+	for flag := range flagContents {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	fileFlagSource := ""
+	for _, flag := range flags {
+		content := flagContents[flag]
+		if content.File[0] && !content.File[1] {
+			if source := FlagSource(cmd, flag, content); source != "" {
+				fileFlagSource = source
+			}
+		}
+	}
+
+	var base map[string]interface{}
+	if baseData != "" && json.Unmarshal([]byte(baseData), &base) == nil {
+		for key := range base {
+			if _, exists := sources.Data[key]; !exists {
+				sources.Data[key] = sources.DataFile
+			}
+		}
+	}
+	var fromFlag map[string]interface{}
+	if flagData != "" && json.Unmarshal([]byte(flagData), &fromFlag) == nil {
+		for key := range fromFlag {
+			sources.Data[key] = fileFlagSource
+		}
+	}
+
+	if metadata != nil {
+		for i, arg := range metadata.Arguments {
+			name := NormalizeArgumentName(arg.Name)
+			if i < len(args) {
+				sources.Data[name] = "argument " + arg.Name
+			} else {
+				sources.Data[name] = fmt.Sprintf("argument %s (not given)", arg.Name)
+			}
+		}
+	}
+	for _, flag := range flags {
+		content := flagContents[flag]
+		if content.File[0] && !content.File[1] {
+			continue
+		}
+		source := FlagSource(cmd, flag, content)
+		if source == "" {
+			continue
+		}
+		key := flag
+		if len(content.Path) > 0 {
+			key = content.Path[0]
+			if previous, exists := sources.Data[key]; exists && previous != sources.DataFile {
+				source = previous + ", " + source
+			}
+		}
+		sources.Data[key] = source
+	}
+}
+
+// inspectedValue is a part of the inspected input along with its source
+type inspectedValue struct {
+	Source interface{} `json:"source,omitempty"`
+	Value  interface{} `json:"value"`
+}
+
+// Inspect returns the input that would be sent to slangroom, annotated with the source of each
+// value. The keys, the data fields considered secret at any depth and the data at the secret
+// paths of the metadata, see SecretPaths, are redacted.
+func Inspect(input slangroom.SlangroomInput, sources InputSources, secretPaths []string) ([]byte, error) {
+	data, err := decodeInputField("data", input.Data)
+	if err != nil {
+		return nil, err
+	}
+	redactSecretFields(data)
+	for _, secretPath := range secretPaths {
+		redactPath(data, strings.Split(secretPath, "."))
+	}
+	dataSources := make(map[string]interface{}, len(sources.Data))
+	for key, source := range sources.Data {
+		dataSources[key] = source
+	}
+
+	inspection := struct {
+		Contract inspectedValue `json:"contract"`
+		Data     inspectedValue `json:"data"`
+		Keys     inspectedValue `json:"keys"`
+		Extra    inspectedValue `json:"extra"`
+		Context  inspectedValue `json:"context"`
+		Conf     inspectedValue `json:"conf"`
+	}{
+		Contract: inspectedValue{Source: sources.Contract, Value: input.Contract},
+		Data:     inspectedValue{Source: dataSources, Value: data},
+	}
+	for _, field := range []struct {
+		name   string
+		value  string
+		source string
+		target *inspectedValue
+	}{
+		{"keys", input.Keys, sources.Keys, &inspection.Keys},
+		{"extra", input.Extra, sources.Extra, &inspection.Extra},
+		{"context", input.Context, sources.Context, &inspection.Context},
+		{"conf", input.Conf, sources.Conf, &inspection.Conf},
+	} {
+		value, err := decodeInputField(field.name, field.value)
+		if err != nil {
+			return nil, err
+		}
+		if field.name == "keys" && value != nil {
+			value = redactAll(value)
+		}
+		field.target.Value = value
+		if field.source != "" {
+			field.target.Source = field.source
+		}
+	}
+
+	return ToJSON(inspection)
+}
+
+// decodeInputField decodes a JSON field of the input, an empty field is decoded as null
+func decodeInputField(name string, content string) (interface{}, error) {
+	if content == "" {
+		return nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return nil, fmt.Errorf("invalid JSON in %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// redactAll replaces every leaf of a JSON value, keeping its structure
+func redactAll(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = redactAll(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactAll(item)
+		}
+		return v
+	default:
+		return Redacted
+	}
+}
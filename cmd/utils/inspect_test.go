@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+	"github.com/spf13/cobra"
+)
+
+func TestDataSources(t *testing.T) {
+	metadata := &CommandMetadata{
+		Arguments: []ArgumentMetadata{{Name: "<username>"}, {Name: "[password]"}},
+		Options: []OptionMetadata{
+			{Name: "-d, --drink <size>"},
+			{Name: "-t, --timeout <delay>", Default: "60", Type: "integer"},
+			{Name: "-p, --port <number>", Env: []string{"TEST_INSPECT_PORT"}},
+			{Name: "--unset"},
+		},
+	}
+	t.Setenv("TEST_INSPECT_PORT", "8080")
+
+	cmd := &cobra.Command{Use: "test"}
+	argContents, flagContents, err := ConfigureArgumentsAndFlags(cmd, metadata, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := cmd.ParseFlags([]string{"--drink", "small"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	input := slangroom.SlangroomInput{}
+	if err := ValidateFlags(cmd, flagContents, argContents, &input); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	sources := InputSources{DataFile: "test.data.json", Data: make(map[string]string)}
+	DataSources(cmd, metadata, flagContents, []string{"alice"}, `{"country": "IT", "drink": "large"}`, "", &sources)
+	expected := map[string]string{
+		"country":  "test.data.json",
+		"username": "argument <username>",
+		"password": "argument [password] (not given)",
+		"drink":    "flag --drink",
+		"timeout":  "default of --timeout",
+		"port":     "environment variable TEST_INSPECT_PORT (--port)",
+	}
+	if len(sources.Data) != len(expected) {
+		t.Errorf("Expected %d sources, got %v", len(expected), sources.Data)
+	}
+	for key, source := range expected {
+		if sources.Data[key] != source {
+			t.Errorf("Expected source %q for %s, got %q", source, key, sources.Data[key])
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	input := slangroom.SlangroomInput{
+		Contract: "Given I have a 'string' named 'username'",
+		Data:     `{"username": "alice", "password": "hunter2", "card": {"number": "4111", "owner": "alice"}, "accounts": [{"name": "bank", "api_token": "t0k3n"}]}`,
+		Keys:     `{"keyring": {"ecdh": "secret key"}, "list": ["a"]}`,
+	}
+	sources := InputSources{
+		Contract: "contracts/test.slang",
+		Data:     map[string]string{"username": "argument <username>"},
+		Keys:     "contracts/test.keys.json",
+	}
+	output, err := Inspect(input, sources, []string{"card.number"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var inspection map[string]struct {
+		Source interface{}
+		Value  interface{}
+	}
+	if err := json.Unmarshal(output, &inspection); err != nil {
+		t.Fatalf("Invalid inspection: %v", err)
+	}
+	data := inspection["data"].Value.(map[string]interface{})
+	if data["username"] != "alice" || data["password"] != Redacted {
+		t.Errorf("Expected only the password to be redacted, got %v", data)
+	}
+	if card := data["card"].(map[string]interface{}); card["number"] != Redacted || card["owner"] != "alice" {
+		t.Errorf("Expected the secret path to be redacted, got %v", card)
+	}
+	if account := data["accounts"].([]interface{})[0].(map[string]interface{}); account["api_token"] != Redacted || account["name"] != "bank" {
+		t.Errorf("Expected the nested secret field to be redacted, got %v", account)
+	}
+	keys := inspection["keys"].Value.(map[string]interface{})
+	if keys["keyring"].(map[string]interface{})["ecdh"] != Redacted || keys["list"].([]interface{})[0] != Redacted {
+		t.Errorf("Expected all the keys to be redacted, got %v", keys)
+	}
+	if inspection["keys"].Source != "contracts/test.keys.json" || inspection["contract"].Source != "contracts/test.slang" {
+		t.Errorf("Unexpected sources %v", inspection)
+	}
+	if inspection["extra"].Value != nil || inspection["extra"].Source != nil {
+		t.Errorf("Expected an empty extra, got %v", inspection["extra"])
+	}
+}
@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+)
+
+// maxInterpolatedFileSize is the size limit of a file read by ${file:path}
+const maxInterpolatedFileSize = 1 << 20
+
+// InterpolationEnvPrefix is the prefix of the environment variables that ${env:VAR} always reads,
+// the other ones must be allowed by the metadata
+const InterpolationEnvPrefix = "TWINROOM_"
+
+// Interpolator replaces the placeholders in the string values of the side files and in the
+// environment of the metadata:
+//
+//	${env:VAR}   the environment variable VAR
+//	${file:path} the content of a file, relative to the directory of the contract
+//	${arg:name}  the value of the argument or flag name of the contract
+//	${now}       the current time in RFC 3339 format
+//	${uuid}      a random UUID
+//
+// A default value follows :-, like ${env:VAR:-default}, and $${ is a literal ${. A missing
+// variable, file or argument without default is an error, an empty argument is missing. The evaluation is sandboxed: nothing
+// is executed, the files are read only inside FS and the environment variables only when they
+// start with InterpolationEnvPrefix or are in AllowEnv.
+type Interpolator struct {
+	// FS holds the files read by ${file:path}, the paths are relative to Dir and cannot leave FS
+	FS  fs.FS
+	Dir string
+	// Args are the values of the arguments and flags of the contract
+	Args map[string]interface{}
+	// AllowEnv are the environment variables read by ${env:VAR} besides the ones that start with
+	// InterpolationEnvPrefix
+	AllowEnv []string
+	// LookupEnv reads an environment variable, os.LookupEnv if nil
+	LookupEnv func(string) (string, bool)
+	// Now returns the time of ${now}, time.Now if nil
+	Now func() time.Time
+}
+
+// Interpolate replaces the placeholders of s
+func (in Interpolator) Interpolate(s string) (string, error) {
+	var out strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			out.WriteString(s)
+			return out.String(), nil
+		}
+		if start > 0 && s[start-1] == '$' {
+			// $${ is an escaped ${
+			out.WriteString(s[:start-1] + "${")
+			s = s[start+2:]
+			continue
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder in %q", s)
+		}
+		value, err := in.resolve(s[start+2 : start+end])
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(s[:start] + value)
+		s = s[start+end+1:]
+	}
+}
+
+// resolve returns the value of the expression of a placeholder, the text between ${ and }
+func (in Interpolator) resolve(expression string) (string, error) {
+	kind, name, _ := strings.Cut(expression, ":")
+	defaultValue, hasDefault := "", false
+	if i := strings.Index(expression, ":-"); i >= 0 {
+		defaultValue, hasDefault = expression[i+2:], true
+		kind, name, _ = strings.Cut(expression[:i], ":")
+	}
+	missing := func(format string, args ...interface{}) (string, error) {
+		if hasDefault {
+			return defaultValue, nil
+		}
+		return "", fmt.Errorf(format, args...)
+	}
+
+	switch kind {
+	case "env":
+		if !strings.HasPrefix(name, InterpolationEnvPrefix) && !slices.Contains(in.AllowEnv, name) {
+			return "", fmt.Errorf("environment variable %s is not allowed, add it to interpolate_env in the metadata", name)
+		}
+		lookupEnv := in.LookupEnv
+		if lookupEnv == nil {
+			lookupEnv = os.LookupEnv
+		}
+		if value, ok := lookupEnv(name); ok {
+			return value, nil
+		}
+		return missing("environment variable %s is not set", name)
+	case "file":
+		filePath := path.Join(in.Dir, name)
+		if in.FS == nil || path.IsAbs(name) || !fs.ValidPath(filePath) {
+			return "", fmt.Errorf("file %s is outside of the contract folder", name)
+		}
+		info, err := fs.Stat(in.FS, filePath)
+		if err != nil || info.IsDir() {
+			return missing("file %s not found", name)
+		}
+		if info.Size() > maxInterpolatedFileSize {
+			return "", fmt.Errorf("file %s is larger than %d bytes", name, maxInterpolatedFileSize)
+		}
+		content, err := fs.ReadFile(in.FS, filePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file %s: %w", name, err)
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(string(content), "\n"), "\r"), nil
+	case "arg":
+		// The arguments that are not given on the command line are empty strings
+		value, ok := in.Args[name]
+		if !ok || value == nil || value == "" {
+			return missing("argument %s is not set", name)
+		}
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid value for argument %s: %w", name, err)
+		}
+		return string(encoded), nil
+	case "now", "uuid":
+		if name != "" || hasDefault {
+			return "", fmt.Errorf("${%s} takes no argument", kind)
+		}
+		if kind == "uuid" {
+			return newUUID()
+		}
+		now := in.Now
+		if now == nil {
+			now = time.Now
+		}
+		return now().UTC().Format(time.RFC3339), nil
+	default:
+		return "", fmt.Errorf("unknown placeholder ${%s}", expression)
+	}
+}
+
+// InterpolateJSON replaces the placeholders in the string values of a JSON document, the keys
+// and the other values are left as they are. An empty document is returned as is.
+func (in Interpolator) InterpolateJSON(content string) (string, error) {
+	if !strings.Contains(content, "${") {
+		return content, nil
+	}
+	var document interface{}
+	if err := json.Unmarshal([]byte(content), &document); err != nil {
+		return "", err
+	}
+	interpolated, err := in.interpolateValue(document)
+	if err != nil {
+		return "", err
+	}
+	result, err := json.Marshal(interpolated)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+func (in Interpolator) interpolateValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return in.Interpolate(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			interpolated, err := in.interpolateValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = interpolated
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			interpolated, err := in.interpolateValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = interpolated
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// InterpolateInput replaces the placeholders of the side files loaded in input, the errors
+// name the files found in sources.
+func (in Interpolator) InterpolateInput(input *slangroom.SlangroomInput, sources InputSources) error {
+	fields := []struct {
+		target *string
+		source string
+	}{
+		{&input.Data, sources.DataFile},
+		{&input.Keys, sources.Keys},
+		{&input.Extra, sources.Extra},
+		{&input.Context, sources.Context},
+		{&input.Conf, sources.Conf},
+	}
+	for _, field := range fields {
+		interpolated, err := in.InterpolateJSON(*field.target)
+		if err != nil {
+			return fmt.Errorf("failed to interpolate %s: %w", field.source, err)
+		}
+		*field.target = interpolated
+	}
+	return nil
+}
+
+// InterpolateEnvironment returns the environment of the metadata with its placeholders replaced
+func (in Interpolator) InterpolateEnvironment(environment map[string]string) (map[string]string, error) {
+	interpolated := make(map[string]string, len(environment))
+	for key, value := range environment {
+		result, err := in.Interpolate(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to interpolate environment variable %s: %w", key, err)
+		}
+		interpolated[key] = result
+	}
+	return interpolated, nil
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate uuid: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+)
+
+func TestInterpolate(t *testing.T) {
+	in := Interpolator{
+		FS: fstest.MapFS{
+			"test/token.txt":   {Data: []byte("secret\n")},
+			"test/nested/a.md": {Data: []byte("a")},
+			"outside.txt":      {Data: []byte("outside")},
+		},
+		Dir:      "test",
+		Args:     map[string]interface{}{"name": "alice", "count": 3, "unset": nil, "omitted": ""},
+		AllowEnv: []string{"HOME", "MISSING"},
+		LookupEnv: func(key string) (string, bool) {
+			switch key {
+			case "HOME", "PATH":
+				return "/home/alice", true
+			case "TWINROOM_USER":
+				return "alice", true
+			}
+			return "", false
+		},
+		Now: func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) },
+	}
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{input: "no placeholders", expected: "no placeholders"},
+		{input: "${env:HOME}/files", expected: "/home/alice/files"},
+		{input: "${env:MISSING:-fallback}", expected: "fallback"},
+		{input: "${env:MISSING:-}", expected: ""},
+		{input: "${env:MISSING}", wantErr: true},
+		{input: "${env:TWINROOM_USER}", expected: "alice"},
+		{input: "${env:PATH}", wantErr: true},
+		{input: "${env:PATH:-/bin}", wantErr: true},
+		{input: "${file:token.txt}", expected: "secret"},
+		{input: "${file:nested/a.md}", expected: "a"},
+		{input: "${file:missing.txt:-none}", expected: "none"},
+		{input: "${file:missing.txt}", wantErr: true},
+		{input: "${file:../outside.txt}", expected: "outside"},
+		{input: "${file:/etc/passwd}", wantErr: true},
+		{input: "${file:../../etc/passwd:-x}", wantErr: true},
+		{input: "${file:nested}", wantErr: true},
+		{input: "hello ${arg:name}, ${arg:count}", expected: "hello alice, 3"},
+		{input: "${arg:unset:-nobody}", expected: "nobody"},
+		{input: "${arg:missing}", wantErr: true},
+		{input: "${arg:omitted:-nobody}", expected: "nobody"},
+		{input: "${arg:omitted}", wantErr: true},
+		{input: "${now}", expected: "2024-05-01T12:00:00Z"},
+		{input: "${now:x}", wantErr: true},
+		{input: "$${env:HOME}", expected: "${env:HOME}"},
+		{input: "${shell:ls}", wantErr: true},
+		{input: "${env:HOME", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := in.Interpolate(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Interpolate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+
+	uuid, err := in.Interpolate("${uuid}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(uuid) {
+		t.Errorf("Expected a version 4 UUID, got %s", uuid)
+	}
+}
+
+func TestInterpolateInput(t *testing.T) {
+	in := Interpolator{Args: map[string]interface{}{"name": "alice"}}
+	input := slangroom.SlangroomInput{
+		Data: `{"user": {"${arg:name}": "${arg:name}", "tags": ["${arg:name}", 1]}, "plain": true}`,
+		Keys: `{"key": "${env:TWINROOM_TEST_MISSING}"}`,
+	}
+	err := in.InterpolateInput(&input, InputSources{Keys: "hello.keys.json"})
+	if err == nil || !strings.Contains(err.Error(), "hello.keys.json") {
+		t.Fatalf("Expected an error naming the keys file, got %v", err)
+	}
+	input.Keys = ""
+	if err := in.InterpolateInput(&input, InputSources{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"plain":true,"user":{"${arg:name}":"alice","tags":["alice",1]}}`
+	if input.Data != expected {
+		t.Errorf("Expected %s, got %s", expected, input.Data)
+	}
+
+	environment, err := in.InterpolateEnvironment(map[string]string{"DIR": "${env:TWINROOM_TEST_MISSING:-contracts}/${arg:name}"})
+	if err != nil || environment["DIR"] != "contracts/alice" {
+		t.Errorf("Unexpected environment %v, %v", environment, err)
+	}
+}
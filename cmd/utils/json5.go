@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// json5ToJSON rewrites a JSON5 document as JSON: comments are removed, unquoted keys and single
+// quoted strings are double quoted, trailing commas are dropped and hexadecimal numbers, leading
+// or trailing decimal points and explicit plus signs are written as JSON numbers. Infinity and
+// NaN cannot be represented in JSON and are rejected.
+func json5ToJSON(content []byte) ([]byte, error) {
+	p := json5Parser{src: content}
+	if err := p.convert(); err != nil {
+		return nil, err
+	}
+	return p.out.Bytes(), nil
+}
+
+type json5Parser struct {
+	src []byte
+	pos int
+	out bytes.Buffer
+	// containers is the stack of the open objects and arrays
+	containers []byte
+	// expectKey is true when the next string or identifier is the key of an object member
+	expectKey bool
+}
+
+// errorf returns an error with the line of the current position
+func (p *json5Parser) errorf(format string, args ...interface{}) error {
+	line := bytes.Count(p.src[:p.pos], []byte("\n")) + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *json5Parser) convert() error {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '/':
+			if err := p.skipComment(true); err != nil {
+				return err
+			}
+		case c == '{' || c == '[':
+			p.containers = append(p.containers, c)
+			p.expectKey = c == '{'
+			p.out.WriteByte(c)
+			p.pos++
+		case c == '}' || c == ']':
+			if len(p.containers) > 0 {
+				p.containers = p.containers[:len(p.containers)-1]
+			}
+			p.expectKey = false
+			p.out.WriteByte(c)
+			p.pos++
+		case c == ',':
+			p.pos++
+			// Trailing commas are dropped
+			if next := p.peekSignificant(); next == '}' || next == ']' {
+				continue
+			}
+			p.out.WriteByte(c)
+			p.expectKey = len(p.containers) > 0 && p.containers[len(p.containers)-1] == '{'
+		case c == ':':
+			p.expectKey = false
+			p.out.WriteByte(c)
+			p.pos++
+		case c == '"' || c == '\'':
+			if err := p.convertString(c); err != nil {
+				return err
+			}
+		case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+			if err := p.convertNumber(); err != nil {
+				return err
+			}
+		case isIdentifierStart(c):
+			if err := p.convertIdentifier(); err != nil {
+				return err
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f':
+			p.out.WriteByte(c)
+			p.pos++
+		default:
+			r, size := utf8.DecodeRune(p.src[p.pos:])
+			if r == '\u00a0' || r == '\ufeff' || r == '\u2028' || r == '\u2029' {
+				// Whitespace allowed by JSON5 but not by JSON
+				p.out.WriteByte(' ')
+				p.pos += size
+				continue
+			}
+			return p.errorf("unexpected character %q", r)
+		}
+	}
+	return nil
+}
+
+// skipComment skips the comment at the current position, keeping its newlines when keepLines
+func (p *json5Parser) skipComment(keepLines bool) error {
+	if p.pos+1 >= len(p.src) {
+		return p.errorf("unexpected character '/'")
+	}
+	switch p.src[p.pos+1] {
+	case '/':
+		end := bytes.IndexByte(p.src[p.pos:], '\n')
+		if end < 0 {
+			p.pos = len(p.src)
+		} else {
+			p.pos += end
+		}
+	case '*':
+		end := bytes.Index(p.src[p.pos+2:], []byte("*/"))
+		if end < 0 {
+			return p.errorf("unterminated comment")
+		}
+		comment := p.src[p.pos : p.pos+2+end+2]
+		if keepLines {
+			p.out.Write(bytes.Repeat([]byte("\n"), bytes.Count(comment, []byte("\n"))))
+		}
+		p.pos += len(comment)
+	default:
+		return p.errorf("unexpected character '/'")
+	}
+	return nil
+}
+
+// peekSignificant returns the next character that is not whitespace or part of a comment
+func (p *json5Parser) peekSignificant() byte {
+	for i := p.pos; i < len(p.src); i++ {
+		switch p.src[i] {
+		case ' ', '\t', '\n', '\r', '\v', '\f':
+			continue
+		case '/':
+			if i+1 < len(p.src) && p.src[i+1] == '/' {
+				end := bytes.IndexByte(p.src[i:], '\n')
+				if end < 0 {
+					return 0
+				}
+				i += end
+				continue
+			}
+			if i+1 < len(p.src) && p.src[i+1] == '*' {
+				end := bytes.Index(p.src[i+2:], []byte("*/"))
+				if end < 0 {
+					return 0
+				}
+				i += 2 + end + 1
+				continue
+			}
+			return '/'
+		default:
+			return p.src[i]
+		}
+	}
+	return 0
+}
+
+// convertString writes the string starting at the current position with double quotes
+func (p *json5Parser) convertString(quote byte) error {
+	p.pos++
+	p.out.WriteByte('"')
+	for {
+		if p.pos >= len(p.src) {
+			return p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.out.WriteByte('"')
+			p.pos++
+			if p.expectKey {
+				p.expectKey = false
+			}
+			return nil
+		case c == '\\':
+			if err := p.convertEscape(); err != nil {
+				return err
+			}
+		case c == '"':
+			p.out.WriteString(`\"`)
+			p.pos++
+		case c == '\n' || c == '\r':
+			return p.errorf("unterminated string")
+		case c < 0x20:
+			fmt.Fprintf(&p.out, `\u%04x`, c)
+			p.pos++
+		default:
+			p.out.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// convertEscape writes the escape sequence at the current position as a JSON one
+func (p *json5Parser) convertEscape() error {
+	if p.pos+1 >= len(p.src) {
+		return p.errorf("unterminated string")
+	}
+	c := p.src[p.pos+1]
+	p.pos += 2
+	switch c {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		p.out.WriteByte('\\')
+		p.out.WriteByte(c)
+	case 'u':
+		p.out.WriteString(`\u`)
+	case '\'':
+		p.out.WriteByte('\'')
+	case 'v':
+		p.out.WriteString(`\u000b`)
+	case '0':
+		p.out.WriteString(`\u0000`)
+	case 'x':
+		if p.pos+2 > len(p.src) {
+			return p.errorf("invalid escape sequence")
+		}
+		value, err := strconv.ParseUint(string(p.src[p.pos:p.pos+2]), 16, 8)
+		if err != nil {
+			return p.errorf("invalid escape sequence \\x%s", p.src[p.pos:p.pos+2])
+		}
+		fmt.Fprintf(&p.out, `\u%04x`, value)
+		p.pos += 2
+	case '\n':
+		// A line continuation is not part of the string
+	case '\r':
+		if p.pos < len(p.src) && p.src[p.pos] == '\n' {
+			p.pos++
+		}
+	default:
+		// Any other escaped character is the character itself
+		p.pos--
+		r, size := utf8.DecodeRune(p.src[p.pos:])
+		p.pos += size
+		if r == '\u2028' || r == '\u2029' {
+			return nil
+		}
+		p.out.WriteRune(r)
+	}
+	return nil
+}
+
+// convertNumber writes the number at the current position as a JSON number
+func (p *json5Parser) convertNumber() error {
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte("+-.0123456789abcdefABCDEFxXInityNa", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	number := string(p.src[start:p.pos])
+	sign := ""
+	if strings.HasPrefix(number, "+") || strings.HasPrefix(number, "-") {
+		if number[0] == '-' {
+			sign = "-"
+		}
+		number = number[1:]
+	}
+	switch {
+	case number == "Infinity" || number == "NaN":
+		return p.errorf("%s cannot be represented in JSON", number)
+	case strings.HasPrefix(number, "0x") || strings.HasPrefix(number, "0X"):
+		value, err := strconv.ParseUint(number[2:], 16, 64)
+		if err != nil {
+			return p.errorf("invalid hexadecimal number %s", number)
+		}
+		number = strconv.FormatUint(value, 10)
+	default:
+		if strings.HasPrefix(number, ".") {
+			number = "0" + number
+		}
+		if strings.HasSuffix(number, ".") {
+			number += "0"
+		}
+		number = strings.Replace(number, ".e", ".0e", 1)
+		number = strings.Replace(number, ".E", ".0E", 1)
+	}
+	p.out.WriteString(sign + number)
+	return nil
+}
+
+// convertIdentifier writes an unquoted key with double quotes, or a literal as is
+func (p *json5Parser) convertIdentifier() error {
+	start := p.pos
+	for p.pos < len(p.src) && (isIdentifierStart(p.src[p.pos]) || (p.src[p.pos] >= '0' && p.src[p.pos] <= '9')) {
+		p.pos++
+	}
+	identifier := string(p.src[start:p.pos])
+	if p.expectKey {
+		p.out.WriteString(strconv.Quote(identifier))
+		p.expectKey = false
+		return nil
+	}
+	switch identifier {
+	case "true", "false", "null":
+		p.out.WriteString(identifier)
+	case "Infinity", "NaN":
+		return p.errorf("%s cannot be represented in JSON", identifier)
+	default:
+		return p.errorf("unexpected identifier %s", identifier)
+	}
+	return nil
+}
+
+// isIdentifierStart reports whether c can start an unquoted key
+func isIdentifierStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= utf8.RuneSelf
+}
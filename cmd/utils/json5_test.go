@@ -0,0 +1,38 @@
+package utils
+
+import "testing"
+
+func TestJSON5ToJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{name: "JSON", input: `{"a": [1, "b"]}`, expected: `{"a": [1, "b"]}`},
+		{name: "Comments", input: "{\n// line\n\"a\": /* inline */ 1}", expected: "{\n\n\"a\":  1}"},
+		{name: "Unquoted keys", input: `{a: 1, $b_2: true}`, expected: `{"a": 1, "$b_2": true}`},
+		{name: "Trailing commas", input: `{"a": [1, 2, ], }`, expected: `{"a": [1, 2 ] }`},
+		{name: "Single quotes", input: `{'a': 'say "hi"'}`, expected: `{"a": "say \"hi\""}`},
+		{name: "Escapes", input: `['\x41\v', 'a\
+b', '\q']`, expected: `["\u0041\u000b", "ab", "q"]`},
+		{name: "Numbers", input: `[0x1F, +1, .5, 5., -.5e2, 1.e3]`, expected: `[31, 1, 0.5, 5.0, -0.5e2, 1.0e3]`},
+		{name: "Value keywords as keys", input: `{null: null, true: false}`, expected: `{"null": null, "true": false}`},
+		{name: "Infinity", input: `{a: Infinity}`, wantErr: true},
+		{name: "NaN", input: `[-NaN]`, wantErr: true},
+		{name: "Unterminated string", input: `{a: 'b}`, wantErr: true},
+		{name: "Unterminated comment", input: `{a: 1} /*`, wantErr: true},
+		{name: "Unknown identifier", input: `{a: b}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := json5ToJSON([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("json5ToJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(result) != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
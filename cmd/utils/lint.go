@@ -0,0 +1,354 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Lint rules reported by LintFS
+const (
+	RuleIntrospectionFailed = "introspection-failed"
+	RuleInvalidMetadata     = "invalid-metadata"
+	RuleInvalidSideFile     = "invalid-side-file"
+	RuleUnreachableInput    = "unreachable-input"
+	RuleUnusedInput         = "unused-input"
+	RuleTypeMismatch        = "type-mismatch"
+)
+
+// LintRules describes every lint rule, keyed by rule id
+var LintRules = map[string]string{
+	RuleIntrospectionFailed: "The contract cannot be introspected by slangroom",
+	RuleInvalidMetadata:     "The metadata file cannot be decoded",
+	RuleInvalidSideFile:     "A data, keys, extra, context or conf file is not valid JSON",
+	RuleUnreachableInput:    "An input of the contract cannot be provided by any argument, option or data file",
+	RuleUnusedInput:         "An argument or option of the metadata is never read by the contract",
+	RuleTypeMismatch:        "The type declared in metadata differs from the type expected by the contract",
+}
+
+// sideFiles are the suffixes of the files loaded along with a contract, see LoadAdditionalData
+var sideFiles = []string{"data", "keys", "extra", "context", "conf"}
+
+// LintFinding is an issue found by cross-checking a contract with its metadata
+type LintFinding struct {
+	Rule    string
+	Level   string // "error" or "warning"
+	File    string
+	Line    int
+	Message string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s [%s]", f.File, f.Line, f.Level, f.Message, f.Rule)
+}
+
+// LintFS introspects every contract found under root in fsys and compares its inputs with the
+// arguments and options of its metadata and with the keys of its side data files.
+func LintFS(fsys fs.FS, root string) ([]LintFinding, error) {
+	var findings []LintFinding
+	err := fs.WalkDir(fsys, root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(filePath, ".slang") {
+			return nil
+		}
+		contract, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, lintContract(fsys, filePath, string(contract))...)
+		return nil
+	})
+	return findings, err
+}
+
+// lintContract reports the findings of a single contract
+func lintContract(fsys fs.FS, contractPath string, contract string) []LintFinding {
+	var findings []LintFinding
+	report := func(rule, file string, line int, format string, args ...interface{}) {
+		level := "error"
+		if rule == RuleUnusedInput {
+			level = "warning"
+		}
+		findings = append(findings, LintFinding{Rule: rule, Level: level, File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+	base := strings.TrimSuffix(contractPath, ".slang")
+
+	// Keys provided by the side data files
+	provided := make(map[string]bool)
+	if err := CheckSideFileFormats(fsys, path.Dir(base), path.Base(base)); err != nil {
+		report(RuleInvalidSideFile, contractPath, 1, "%v", err)
+	}
+	for _, side := range sideFiles {
+		sidePath, err := FindSideFile(fsys, base+"."+side)
+		if err != nil {
+			report(RuleInvalidSideFile, base+"."+side+".json", 1, "%v", err)
+			continue
+		}
+		if sidePath == "" {
+			continue
+		}
+		content, err := ReadSideFile(fsys, sidePath)
+		if err != nil {
+			report(RuleInvalidSideFile, sidePath, 1, "%v", err)
+			continue
+		}
+		var values map[string]interface{}
+		if err := json.Unmarshal(content, &values); err != nil {
+			line := 1
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				line = strings.Count(string(content[:syntaxErr.Offset]), "\n") + 1
+			}
+			report(RuleInvalidSideFile, sidePath, line, "invalid JSON: %v", err)
+			continue
+		}
+		for key := range values {
+			provided[key] = true
+		}
+	}
+
+	// The introspection is shared with the commands, so each contract is introspected once
+	raw, err := IntrospectContract(contract)
+	if err != nil {
+		report(RuleIntrospectionFailed, contractPath, 1, "%v", err)
+		return findings
+	}
+	var introspection Introspection
+	if err := json.Unmarshal([]byte(raw), &introspection); err != nil {
+		report(RuleIntrospectionFailed, contractPath, 1, "invalid introspection: %v", err)
+		return findings
+	}
+
+	metadataPath, err := FindSideFile(fsys, base+".metadata")
+	if err != nil {
+		report(RuleInvalidMetadata, base+".metadata.json", 1, "%v", err)
+		return findings
+	}
+	if metadataPath == "" {
+		// Without metadata every input gets its own flag from the introspection
+		return findings
+	}
+	metadataContent, err := ReadSideFile(fsys, metadataPath)
+	if err != nil {
+		report(RuleInvalidMetadata, metadataPath, 1, "%v", err)
+		return findings
+	}
+	var metadata CommandMetadata
+	if err := json.Unmarshal(metadataContent, &metadata); err != nil {
+		report(RuleInvalidMetadata, metadataPath, 1, "failed to decode metadata: %v", err)
+		return findings
+	}
+	// The lines are known only for the JSON files, the other formats are reported at line 1
+	var lines map[string]int
+	if sideFileFormat(metadataPath) == "JSON" {
+		lines = jsonLines(metadataContent)
+	}
+
+	// Inputs provided by the metadata, with the pointer and the type of their declaration
+	type declaration struct {
+		pointer  string
+		typeStr  string
+		explicit bool
+	}
+	declared := make(map[string]declaration)
+	// File options without rawdata merge arbitrary keys in the data
+	opaque := false
+	for i, arg := range metadata.Arguments {
+		declared[NormalizeArgumentName(arg.Name)] = declaration{
+			pointer:  fmt.Sprintf("/arguments/%d", i),
+			typeStr:  declaredType(ArgumentType(arg), arg.Schema),
+			explicit: arg.Type != "" || IsVariadicArgument(arg.Name) || arg.Schema != nil,
+		}
+	}
+	for i, opt := range metadata.Options {
+		if opt.File && !opt.RawData {
+			opaque = true
+			continue
+		}
+		typeStr := opt.Type
+		if len(opt.Properties) > 0 {
+			typeStr = "object"
+		}
+		declared[GetFlagName(opt.Name)] = declaration{
+			pointer:  fmt.Sprintf("/options/%d", i),
+			typeStr:  declaredType(typeStr, opt.Schema),
+			explicit: opt.Type != "" || len(opt.Properties) > 0 || opt.Schema != nil,
+		}
+	}
+
+	names := make([]string, 0, len(introspection))
+	for name := range introspection {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		info := introspection[name]
+		decl, ok := declared[name]
+		if !ok {
+			if !provided[name] && !opaque {
+				report(RuleUnreachableInput, contractPath, contractLine(contract, name),
+					"input %s is not provided by any argument, option or data file", name)
+			}
+			continue
+		}
+		expected := introspectionFlagType(info)
+		if decl.explicit && !compatibleTypes(decl.typeStr, expected) {
+			report(RuleTypeMismatch, metadataPath, lineOf(lines, decl.pointer+"/type"),
+				"%s is declared as %s but the contract expects %s (%s)", name, decl.typeStr, expected, describeCodec(info))
+		}
+	}
+
+	inputs := make([]string, 0, len(declared))
+	for name := range declared {
+		inputs = append(inputs, name)
+	}
+	sort.Strings(inputs)
+	for _, name := range inputs {
+		if _, ok := introspection[name]; ok {
+			continue
+		}
+		// Statements like "send path 'x'" read inputs that introspection does not list
+		if strings.Contains(contract, "'"+name+"'") {
+			continue
+		}
+		report(RuleUnusedInput, metadataPath, lineOf(lines, declared[name].pointer+"/name"),
+			"%s is never read by the contract", name)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
+
+// declaredType returns the JSON type of an input, preferring the type of its JSON Schema
+func declaredType(typeStr string, schema map[string]interface{}) string {
+	if schemaType, ok := schema["type"].(string); ok {
+		return schemaType
+	}
+	return jsonSchemaType(typeStr)
+}
+
+// compatibleTypes reports whether a value of the declared JSON type is accepted by the contract
+func compatibleTypes(declared, expected string) bool {
+	return declared == expected || (declared == "integer" && expected == "number")
+}
+
+// describeCodec formats the zenroom encoding and zentype of an input
+func describeCodec(info codec) string {
+	return fmt.Sprintf("encoding %s, zentype %s", info.Encoding, info.Zentype)
+}
+
+// contractLine returns the line of the contract where the input is named, or 1
+func contractLine(contract string, name string) int {
+	for i, line := range strings.Split(contract, "\n") {
+		if strings.Contains(line, "'"+name+"'") {
+			return i + 1
+		}
+	}
+	return 1
+}
+
+// sarifLog is the subset of the SARIF 2.1.0 format produced by SARIFReport
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// SARIFReport encodes the lint findings as a SARIF 2.1.0 log, so that CI can annotate them
+func SARIFReport(toolName string, findings []LintFinding) ([]byte, error) {
+	ruleIDs := make([]string, 0, len(LintRules))
+	for id := range LintRules {
+		ruleIDs = append(ruleIDs, id)
+	}
+	sort.Strings(ruleIDs)
+	rules := make([]sarifRule, len(ruleIDs))
+	for i, id := range ruleIDs {
+		rules[i] = sarifRule{ID: id, ShortDescription: sarifMessage{Text: LintRules[id]}}
+	}
+
+	results := make([]sarifResult, len(findings))
+	for i, finding := range findings {
+		results[i] = sarifResult{
+			RuleID:  finding.Rule,
+			Level:   finding.Level,
+			Message: sarifMessage{Text: finding.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(finding.File)},
+				Region:           sarifRegion{StartLine: finding.Line},
+			}}},
+		}
+	}
+
+	return json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           toolName,
+				InformationURI: "https://github.com/forkbombeu/twinroom",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}, "", "  ")
+}
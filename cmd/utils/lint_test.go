@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLintFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"contracts/login.slang": {Data: []byte(`Given I have a 'string' named 'username'
+Given I have a 'number' named 'timeout'
+Given I have a 'string' named 'token'
+Given I have a 'string' named 'secret'
+Then print the data`)},
+		"contracts/login.metadata.json": {Data: []byte(`{
+    "arguments": [
+        {"name": "<username>"},
+        {"name": "[password]"}
+    ],
+    "options": [
+        {"name": "--timeout", "type": "boolean"}
+    ]
+}`)},
+		"contracts/login.keys.json":  {Data: []byte(`{"secret": "s3cr3t"}`)},
+		"contracts/login.extra.json": {Data: []byte("{\n  \"broken\": \n}")},
+		"contracts/plain.slang":      {Data: []byte(`Given I have a 'string' named 'anything'`)},
+	}
+
+	findings, err := LintFS(fsys, "contracts")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []LintFinding{
+		{Rule: RuleInvalidSideFile, Level: "error", File: "contracts/login.extra.json", Line: 3},
+		{Rule: RuleUnusedInput, Level: "warning", File: "contracts/login.metadata.json", Line: 4},
+		{Rule: RuleTypeMismatch, Level: "error", File: "contracts/login.metadata.json", Line: 7},
+		{Rule: RuleUnreachableInput, Level: "error", File: "contracts/login.slang", Line: 3},
+	}
+	if len(findings) != len(expected) {
+		t.Fatalf("Expected %d findings, got %v", len(expected), findings)
+	}
+	for i, finding := range findings {
+		if finding.Rule != expected[i].Rule || finding.Level != expected[i].Level ||
+			finding.File != expected[i].File || finding.Line != expected[i].Line {
+			t.Errorf("Expected %+v, got %+v", expected[i], finding)
+		}
+	}
+}
+
+func TestLintFSIntrospectionFailed(t *testing.T) {
+	previous := introspect
+	introspect = func(string) (string, error) {
+		return "", errors.New("slangroom-exec not found")
+	}
+	t.Cleanup(func() { introspect = previous })
+
+	fsys := fstest.MapFS{
+		"contracts/failing.slang": {Data: []byte(`Given I have a 'string' named 'lint_introspection_failed'`)},
+	}
+	findings, err := LintFS(fsys, "contracts")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Rule != RuleIntrospectionFailed || !strings.Contains(findings[0].Message, "slangroom-exec not found") {
+		t.Errorf("Expected the failed introspection to be reported, got %v", findings)
+	}
+}
+
+func TestSARIFReport(t *testing.T) {
+	findings := []LintFinding{
+		{Rule: RuleUnusedInput, Level: "warning", File: "contracts/login.metadata.json", Line: 4, Message: "password is never read by the contract"},
+	}
+	report, err := SARIFReport("twinroom", findings)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(report, &log); err != nil {
+		t.Fatalf("Invalid SARIF report: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected a single SARIF 2.1.0 run, got %s", report)
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "twinroom" || len(run.Tool.Driver.Rules) != len(LintRules) {
+		t.Errorf("Expected the twinroom driver with every rule, got %+v", run.Tool.Driver)
+	}
+	if len(run.Results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(run.Results))
+	}
+	result := run.Results[0]
+	location := result.Locations[0].PhysicalLocation
+	if result.RuleID != RuleUnusedInput || result.Level != "warning" ||
+		location.ArtifactLocation.URI != "contracts/login.metadata.json" || location.Region.StartLine != 4 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
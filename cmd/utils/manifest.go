@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ManifestFile is the name of the manifest, generated at build time in the root of the contracts
+const ManifestFile = "twinroom.manifest.json"
+
+// ManifestVersion is the version of the manifest format, a manifest with another version is ignored
+const ManifestVersion = 1
+
+// Manifest holds what the CLI and the HTTP server compute for each embedded contract, so that
+// they do not parse the metadata nor spawn slangroom-exec for the introspection at startup.
+type Manifest struct {
+	Version int `json:"version"`
+	// Contracts are keyed by the path of the contract in the embedded folder, like contracts/hello.slang
+	Contracts map[string]ManifestEntry `json:"contracts"`
+}
+
+// ManifestEntry is the precompiled description of a contract
+type ManifestEntry struct {
+	// Hash is the sha256 of the contract, an entry that does not match the contract is ignored
+	Hash string `json:"hash"`
+	// Metadata is the parsed metadata of the contract, nil when it has no metadata
+	Metadata *CommandMetadata `json:"metadata,omitempty"`
+	// MetadataError is the error found in the metadata, reported when the contract is used
+	MetadataError string `json:"metadata_error,omitempty"`
+	// Introspection is the cleaned introspection of the contract, empty if it failed
+	Introspection string `json:"introspection,omitempty"`
+	// Schema is the JSON Schema of the input derived from the metadata, see InputSchema
+	Schema map[string]interface{} `json:"schema,omitempty"`
+}
+
+// BuildManifest describes the contracts found under root of fsys. The introspection runs for
+// every contract, so that the list command finds the inputs in the manifest as well.
+func BuildManifest(fsys fs.FS, root string) (*Manifest, error) {
+	manifest := &Manifest{Version: ManifestVersion, Contracts: make(map[string]ManifestEntry)}
+	err := fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != ".slang" {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		// A contract that cannot be introspected is introspected again when it is used
+		introspection, _ := IntrospectContract(string(content))
+		entry := ManifestEntry{
+			Hash:          ContractHash(string(content)),
+			Introspection: introspection,
+		}
+		metadata, err := LoadMetadataFS(fsys, strings.TrimSuffix(p, ".slang")+".metadata.json")
+		switch {
+		case err == nil:
+			entry.Metadata = metadata
+			entry.Schema, _ = InputSchema(metadata)
+		case err.Error() != "metadata file not found":
+			entry.MetadataError = err.Error()
+		}
+		manifest.Contracts[p] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contracts: %w", err)
+	}
+	return manifest, nil
+}
+
+// ReadManifest reads the manifest in root of fsys. It returns nil without error when there is
+// no manifest, for the contracts that are not built with make build.
+func ReadManifest(fsys fs.FS, root string) (*Manifest, error) {
+	content, err := fs.ReadFile(fsys, path.Join(root, ManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if manifest.Version != ManifestVersion {
+		return nil, fmt.Errorf("manifest version %d is not supported, rebuild it with version %d", manifest.Version, ManifestVersion)
+	}
+	return &manifest, nil
+}
+
+// Entry returns the entry of the contract at contractPath, if the manifest has one for its content
+func (m *Manifest) Entry(contractPath string, content string) (ManifestEntry, bool) {
+	if m == nil {
+		return ManifestEntry{}, false
+	}
+	entry, ok := m.Contracts[path.Clean(contractPath)]
+	if !ok || entry.Hash != ContractHash(content) {
+		return ManifestEntry{}, false
+	}
+	return entry, true
+}
+
+// LoadMetadata returns the metadata of the entry, with the error found at build time and the
+// "metadata file not found" error of LoadMetadata when the contract has no metadata
+func (e ManifestEntry) LoadMetadata() (*CommandMetadata, error) {
+	switch {
+	case e.MetadataError != "":
+		return nil, errors.New(e.MetadataError)
+	case e.Metadata == nil:
+		return nil, fmt.Errorf("metadata file not found")
+	default:
+		return e.Metadata, nil
+	}
+}
+
+// UseManifest fills the introspection cache with the introspection of the manifest, so that
+// IntrospectContract spawns slangroom-exec only for the contracts it does not know
+func UseManifest(m *Manifest) {
+	if m == nil {
+		return
+	}
+	for _, entry := range m.Contracts {
+		if entry.Introspection != "" {
+			introspectionCache.Store(entry.Hash, entry.Introspection)
+		}
+	}
+}
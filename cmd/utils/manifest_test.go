@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBuildManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"contracts/hello.slang":               {Data: []byte(`Given nothing`)},
+		"contracts/test/login.slang":          {Data: []byte(`Given I have a 'string' named 'username'`)},
+		"contracts/test/login.metadata.yaml":  {Data: []byte("description: login\nschema:\n  type: object\n")},
+		"contracts/test/broken.slang":         {Data: []byte(`Given nothing`)},
+		"contracts/test/broken.metadata.json": {Data: []byte(`{"description": }`)},
+		"contracts/test/notes.txt":            {Data: []byte(`not a contract`)},
+	}
+
+	manifest, err := BuildManifest(fsys, "contracts")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if manifest.Version != ManifestVersion || len(manifest.Contracts) != 3 {
+		t.Fatalf("Expected the three contracts, got %+v", manifest)
+	}
+
+	login := manifest.Contracts["contracts/test/login.slang"]
+	if login.Metadata == nil || login.Metadata.Description != "login" || login.Schema["type"] != "object" {
+		t.Errorf("Expected the metadata and the schema of login, got %+v", login)
+	}
+	if login.Hash != ContractHash(`Given I have a 'string' named 'username'`) {
+		t.Errorf("Expected the hash of login, got %s", login.Hash)
+	}
+	if hello := manifest.Contracts["contracts/hello.slang"]; hello.Metadata != nil || hello.MetadataError != "" {
+		t.Errorf("Expected no metadata for hello, got %+v", hello)
+	}
+	if broken := manifest.Contracts["contracts/test/broken.slang"]; broken.MetadataError == "" {
+		t.Errorf("Expected the metadata error of broken, got %+v", broken)
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	contract := `Given I have a 'string' named 'manifest_only'`
+	manifest := Manifest{
+		Version: ManifestVersion,
+		Contracts: map[string]ManifestEntry{
+			"contracts/test/login.slang": {
+				Hash:          ContractHash(contract),
+				Metadata:      &CommandMetadata{Description: "login"},
+				Introspection: `{"manifest_only":{"encoding":"string","name":"manifest_only","zentype":"e"}}`,
+			},
+		},
+	}
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"contracts/" + ManifestFile: {Data: content}}
+
+	read, err := ReadManifest(fsys, "contracts")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entry, ok := read.Entry("contracts/test/login.slang", contract)
+	if !ok {
+		t.Fatal("Expected the entry of login")
+	}
+	if metadata, err := entry.LoadMetadata(); err != nil || metadata.Description != "login" {
+		t.Errorf("Expected the metadata of login, got %+v, %v", metadata, err)
+	}
+	if _, ok := read.Entry("contracts/test/login.slang", contract+"\nThen print the data"); ok {
+		t.Error("Expected no entry for a changed contract")
+	}
+
+	UseManifest(read)
+	if introspection, err := IntrospectContract(contract); err != nil || introspection != manifest.Contracts["contracts/test/login.slang"].Introspection {
+		t.Errorf("Expected the introspection of the manifest, got %s, %v", introspection, err)
+	}
+
+	if read, err := ReadManifest(fstest.MapFS{}, "contracts"); read != nil || err != nil {
+		t.Errorf("Expected no manifest and no error, got %+v, %v", read, err)
+	}
+	fsys["contracts/"+ManifestFile] = &fstest.MapFile{Data: []byte(`{"version": 99}`)}
+	if _, err := ReadManifest(fsys, "contracts"); err == nil {
+		t.Error("Expected an error for an unsupported version")
+	}
+
+	var missing *Manifest
+	if _, ok := missing.Entry("contracts/test/login.slang", contract); ok {
+		t.Error("Expected no entry without manifest")
+	}
+	if _, err := (ManifestEntry{}).LoadMetadata(); err == nil || err.Error() != "metadata file not found" {
+		t.Errorf("Expected metadata file not found, got %v", err)
+	}
+	if _, err := (ManifestEntry{MetadataError: "bad metadata"}).LoadMetadata(); err == nil || err.Error() != "bad metadata" {
+		t.Errorf("Expected the metadata error, got %v", err)
+	}
+}
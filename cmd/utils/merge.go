@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Merge modes of the JSON documents that make up the input of a contract
+const (
+	// MergeDeep merges the objects recursively and the arrays with the array strategy
+	MergeDeep = "deep"
+	// MergeShallow replaces the top level keys, like MergeJSON
+	MergeShallow = "shallow"
+	// MergePatch applies the overlay as a JSON Merge Patch (RFC 7396): objects are merged
+	// recursively, null removes a key and any other value, arrays included, is replaced
+	MergePatch = "patch"
+)
+
+// MergeModes are the values accepted by the --merge flag
+var MergeModes = []string{MergeDeep, MergeShallow, MergePatch}
+
+// Strategies of the deep merge for two arrays
+const (
+	// ArraysReplace replaces the array of the base with the one of the overlay
+	ArraysReplace = "replace"
+	// ArraysAppend appends the items of the overlay to the array of the base
+	ArraysAppend = "append"
+	// ArraysIndex merges the items with the same index, the extra items of the overlay are appended
+	ArraysIndex = "index"
+)
+
+// ArrayStrategies are the values accepted by the --merge-arrays flag
+var ArrayStrategies = []string{ArraysReplace, ArraysAppend, ArraysIndex}
+
+// MergeOptions describes how two JSON documents are merged
+type MergeOptions struct {
+	// Mode is one of MergeModes, deep if empty
+	Mode string
+	// Arrays is one of ArrayStrategies, replace if empty. It is used only by the deep merge.
+	Arrays string
+}
+
+// Validate checks the mode and the array strategy of the options
+func (opts MergeOptions) Validate() error {
+	if err := ValidateMergeMode(opts.Mode); err != nil {
+		return err
+	}
+	for _, strategy := range ArrayStrategies {
+		if opts.Arrays == "" || opts.Arrays == strategy {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid merge arrays %s, use one of %s", opts.Arrays, strings.Join(ArrayStrategies, ", "))
+}
+
+// ValidateMergeMode checks that mode is one of MergeModes, an empty mode is the deep one
+func ValidateMergeMode(mode string) error {
+	if mode == "" {
+		return nil
+	}
+	for _, m := range MergeModes {
+		if mode == m {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid merge %s, use one of %s", mode, strings.Join(MergeModes, ", "))
+}
+
+// MergeSource is a JSON document to merge along with its source, like the file it was read from
+type MergeSource struct {
+	Name    string
+	Content string
+}
+
+// MergeDiagnostic tells which source won for a value of the merged document, the value is
+// identified by its JSON pointer
+type MergeDiagnostic struct {
+	Path   string `json:"path"`
+	Source string `json:"source"`
+}
+
+// Merge combines two JSON documents, the values of overlay win over the ones of base. An empty
+// document is ignored.
+func Merge(base, overlay string, opts MergeOptions) (string, error) {
+	merged, _, err := mergeDocuments([]MergeSource{{Content: base}, {Content: overlay}}, opts, false)
+	return merged, err
+}
+
+// MergeDocuments merges the documents in order, the later ones win, and reports which source
+// won for each leaf of the merged document. Empty documents are ignored.
+func MergeDocuments(docs []MergeSource, opts MergeOptions) (string, []MergeDiagnostic, error) {
+	return mergeDocuments(docs, opts, true)
+}
+
+// mergeDocuments merges the documents in order, the winners of the leaves are tracked only when
+// diagnose is set
+func mergeDocuments(docs []MergeSource, opts MergeOptions, diagnose bool) (string, []MergeDiagnostic, error) {
+	if err := opts.Validate(); err != nil {
+		return "", nil, err
+	}
+	if opts.Mode == "" {
+		opts.Mode = MergeDeep
+	}
+	m := merger{opts: opts}
+	if diagnose {
+		m.winners = &winnerNode{}
+	}
+	var merged interface{}
+	found := false
+	for i, doc := range docs {
+		if strings.TrimSpace(doc.Content) == "" {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(doc.Content), &value); err != nil {
+			return "", nil, fmt.Errorf("error decoding JSON%d: %v", i+1, err)
+		}
+		if !found {
+			found = true
+			merged = m.replace("", value, doc.Name)
+			continue
+		}
+		merged = m.merge(merged, value, "", doc.Name, true)
+	}
+	if !found {
+		return "", nil, nil
+	}
+
+	result, err := json.Marshal(merged)
+	if err != nil {
+		return "", nil, fmt.Errorf("error encoding merged JSON: %v", err)
+	}
+	if !diagnose {
+		return string(result), nil, nil
+	}
+	diagnostics := []MergeDiagnostic{}
+	m.winners.collect("", &diagnostics)
+	sort.Slice(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Path < diagnostics[j].Path
+	})
+	return string(result), diagnostics, nil
+}
+
+// merger merges JSON values and records the source of each leaf by its JSON pointer, the
+// sources are not recorded when winners is nil
+type merger struct {
+	opts    MergeOptions
+	winners *winnerNode
+}
+
+// winnerNode holds the source of the value at a JSON pointer and the nodes of its children by
+// their escaped key, so that forgetting a value and its children removes a single node
+type winnerNode struct {
+	source   string
+	won      bool
+	children map[string]*winnerNode
+}
+
+// collect appends the sources of the node and of its children, path is the pointer of the node
+func (n *winnerNode) collect(path string, diagnostics *[]MergeDiagnostic) {
+	if n.won {
+		*diagnostics = append(*diagnostics, MergeDiagnostic{Path: path, Source: n.source})
+	}
+	for key, child := range n.children {
+		child.collect(path+"/"+key, diagnostics)
+	}
+}
+
+// merge merges overlay into base, the top level values are merged also in the shallow mode
+func (m *merger) merge(base, overlay interface{}, path string, source string, top bool) interface{} {
+	switch o := overlay.(type) {
+	case map[string]interface{}:
+		b, ok := base.(map[string]interface{})
+		if m.opts.Mode == MergePatch && !ok {
+			// A patch object applies to an empty object when the target is not one
+			b = make(map[string]interface{})
+			m.forget(path)
+		} else if !ok || (m.opts.Mode == MergeShallow && !top) {
+			return m.replace(path, overlay, source)
+		}
+		for key, value := range o {
+			keyPath := path + "/" + escapePointer(key)
+			if value == nil && m.opts.Mode == MergePatch {
+				delete(b, key)
+				m.forget(keyPath)
+				continue
+			}
+			b[key] = m.merge(b[key], value, keyPath, source, false)
+		}
+		return b
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok || m.opts.Mode != MergeDeep || (m.opts.Arrays != ArraysAppend && m.opts.Arrays != ArraysIndex) {
+			return m.replace(path, overlay, source)
+		}
+		for i, item := range o {
+			if m.opts.Arrays == ArraysIndex && i < len(b) {
+				b[i] = m.merge(b[i], item, path+"/"+strconv.Itoa(i), source, false)
+				continue
+			}
+			b = append(b, m.replace(path+"/"+strconv.Itoa(len(b)), item, source))
+		}
+		return b
+	default:
+		return m.replace(path, overlay, source)
+	}
+}
+
+// replace records source as the winner of every leaf of value, in place of the previous ones
+func (m *merger) replace(path string, value interface{}, source string) interface{} {
+	m.forget(path)
+	m.record(path, value, source)
+	return value
+}
+
+// record records source as the winner of every leaf of value, empty objects and arrays are leaves
+func (m *merger) record(path string, value interface{}, source string) {
+	if m.winners == nil {
+		return
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) > 0 {
+			for key, item := range v {
+				m.record(path+"/"+escapePointer(key), item, source)
+			}
+			return
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			for i, item := range v {
+				m.record(path+"/"+strconv.Itoa(i), item, source)
+			}
+			return
+		}
+	}
+	node := m.winners
+	for _, key := range pointerKeys(path) {
+		child, ok := node.children[key]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[string]*winnerNode)
+			}
+			child = &winnerNode{}
+			node.children[key] = child
+		}
+		node = child
+	}
+	node.source = source
+	node.won = true
+}
+
+// forget removes the winners of the value at path and of its children
+func (m *merger) forget(path string) {
+	if m.winners == nil {
+		return
+	}
+	keys := pointerKeys(path)
+	if len(keys) == 0 {
+		*m.winners = winnerNode{}
+		return
+	}
+	node := m.winners
+	for _, key := range keys[:len(keys)-1] {
+		if node = node.children[key]; node == nil {
+			return
+		}
+	}
+	delete(node.children, keys[len(keys)-1])
+}
+
+// pointerKeys splits a JSON pointer into its escaped keys, the empty pointer has no keys
+func pointerKeys(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path[1:], "/")
+}
+
+// escapePointer escapes a key to be used in a JSON pointer (RFC 6901)
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// mergeOptions returns the merge options chosen with the --merge and --merge-arrays flags of the
+// command, the default ones if the command has no such flags.
+func mergeOptions(cmd *cobra.Command) MergeOptions {
+	var opts MergeOptions
+	if cmd == nil {
+		return opts
+	}
+	if f := cmd.Flags().Lookup("merge"); f != nil {
+		opts.Mode = f.Value.String()
+	}
+	if f := cmd.Flags().Lookup("merge-arrays"); f != nil {
+		opts.Arrays = f.Value.String()
+	}
+	if opts.Validate() != nil {
+		return MergeOptions{}
+	}
+	return opts
+}
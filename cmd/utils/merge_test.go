@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		overlay  string
+		opts     MergeOptions
+		expected string
+	}{
+		{
+			name:     "Deep merge of nested objects",
+			base:     `{"user": {"name": "a", "role": "b"}, "list": [1, 2]}`,
+			overlay:  `{"user": {"name": "c"}, "list": [3]}`,
+			expected: `{"list":[3],"user":{"name":"c","role":"b"}}`,
+		},
+		{
+			name:     "Shallow merge replaces the top level keys",
+			base:     `{"user": {"name": "a", "role": "b"}}`,
+			overlay:  `{"user": {"name": "c"}}`,
+			opts:     MergeOptions{Mode: MergeShallow},
+			expected: `{"user":{"name":"c"}}`,
+		},
+		{
+			name:     "Append arrays",
+			base:     `{"list": [1, 2]}`,
+			overlay:  `{"list": [3]}`,
+			opts:     MergeOptions{Mode: MergeDeep, Arrays: ArraysAppend},
+			expected: `{"list":[1,2,3]}`,
+		},
+		{
+			name:     "Merge arrays by index",
+			base:     `{"list": [{"a": 1, "b": 2}, 5]}`,
+			overlay:  `{"list": [{"a": 3}, 6, 7]}`,
+			opts:     MergeOptions{Arrays: ArraysIndex},
+			expected: `{"list":[{"a":3,"b":2},6,7]}`,
+		},
+		{
+			name:     "Arrays as documents",
+			base:     `[1, 2]`,
+			overlay:  `[3]`,
+			opts:     MergeOptions{Arrays: ArraysAppend},
+			expected: `[1,2,3]`,
+		},
+		{
+			name:     "JSON Merge Patch",
+			base:     `{"a": "b", "c": {"d": "e", "f": "g"}, "list": [1]}`,
+			overlay:  `{"a": "z", "c": {"f": null}, "list": [2], "n": {"x": null}}`,
+			opts:     MergeOptions{Mode: MergePatch, Arrays: ArraysAppend},
+			expected: `{"a":"z","c":{"d":"e"},"list":[2],"n":{}}`,
+		},
+		{
+			name:     "Empty base",
+			base:     "",
+			overlay:  `{"user": "c"}`,
+			expected: `{"user":"c"}`,
+		},
+		{
+			name:     "Empty overlay",
+			base:     `{"user": "a"}`,
+			overlay:  "",
+			expected: `{"user":"a"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Merge(tt.base, tt.overlay, tt.opts)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+
+	if _, err := Merge(`{"a": 1}`, `{"a": `, MergeOptions{}); err == nil {
+		t.Errorf("Expected an error merging an invalid document")
+	}
+	if err := (MergeOptions{Mode: "overwrite"}).Validate(); err == nil {
+		t.Errorf("Expected an error for an unknown merge mode")
+	}
+	if err := (MergeOptions{Arrays: "zip"}).Validate(); err == nil {
+		t.Errorf("Expected an error for an unknown array strategy")
+	}
+}
+
+func TestMergeDocuments(t *testing.T) {
+	docs := []MergeSource{
+		{Name: "test.data.json", Content: `{"user": {"name": "a", "role": "b"}, "tags": ["x"]}`},
+		{Name: "flag --data", Content: `{"user": {"name": "c"}, "tags": ["y"]}`},
+		{Name: "missing", Content: ""},
+		{Name: "arguments and flags", Content: `{"drink": "small"}`},
+	}
+	merged, diagnostics, err := MergeDocuments(docs, MergeOptions{Arrays: ArraysAppend})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if merged != `{"drink":"small","tags":["x","y"],"user":{"name":"c","role":"b"}}` {
+		t.Errorf("Unexpected merged document %s", merged)
+	}
+	expected := []MergeDiagnostic{
+		{Path: "/drink", Source: "arguments and flags"},
+		{Path: "/tags/0", Source: "test.data.json"},
+		{Path: "/tags/1", Source: "flag --data"},
+		{Path: "/user/name", Source: "flag --data"},
+		{Path: "/user/role", Source: "test.data.json"},
+	}
+	if !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("Expected diagnostics %v, got %v", expected, diagnostics)
+	}
+
+	_, diagnostics, err = MergeDocuments([]MergeSource{
+		{Name: "base", Content: `{"user": {"name": "a"}}`},
+		{Name: "patch", Content: `{"user": null}`},
+	}, MergeOptions{Mode: MergePatch})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(diagnostics) != 0 {
+		t.Errorf("Expected the removed values to have no source, got %v", diagnostics)
+	}
+
+	// A replaced object forgets the sources of all its children
+	_, diagnostics, err = MergeDocuments([]MergeSource{
+		{Name: "base", Content: `{"user": {"name": "a", "home": {"city": "b"}}, "id": 1}`},
+		{Name: "overlay", Content: `{"user": {"role": "c"}}`},
+	}, MergeOptions{Mode: MergeShallow})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected = []MergeDiagnostic{
+		{Path: "/id", Source: "base"},
+		{Path: "/user/role", Source: "overlay"},
+	}
+	if !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("Expected diagnostics %v, got %v", expected, diagnostics)
+	}
+}
@@ -0,0 +1,293 @@
+package utils
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	jsschema "github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// metadataSchema is the JSON Schema of the metadata.json files
+//
+//go:embed metadata.schema.json
+var metadataSchema []byte
+
+// MetadataSchema returns the JSON Schema of the metadata.json files
+func MetadataSchema() []byte {
+	return metadataSchema
+}
+
+// MetadataProblem is an issue found in a metadata file
+type MetadataProblem struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (p MetadataProblem) String() string {
+	return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+}
+
+var additionalPropertiesRegexp = regexp.MustCompile(`'([^']+)'`)
+
+// ValidateMetadataFS validates every metadata file found under root in fsys against the
+// contract next to it, see ValidateMetadata. The JSON5 and YAML metadata files are converted
+// to JSON first and their problems are reported at line 1.
+func ValidateMetadataFS(fsys fs.FS, root string) ([]MetadataProblem, error) {
+	var problems []MetadataProblem
+	err := fs.WalkDir(fsys, root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		format := sideFileFormat(filePath)
+		stem := strings.TrimSuffix(filePath, path.Ext(filePath))
+		if d.IsDir() || format == "" || !strings.HasSuffix(stem, ".metadata") {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+		contractPath := strings.TrimSuffix(stem, ".metadata") + ".slang"
+		if err := CheckSideFileFormats(fsys, path.Dir(stem), path.Base(strings.TrimSuffix(stem, ".metadata"))); err != nil {
+			problems = append(problems, MetadataProblem{File: filePath, Line: 1, Message: err.Error()})
+		}
+		if format != "JSON" {
+			converted, err := convertSideFile(filePath, content)
+			if err != nil {
+				problems = append(problems, MetadataProblem{File: filePath, Line: 1, Message: err.Error()})
+				return nil
+			}
+			content = converted
+		}
+		contract, err := fs.ReadFile(fsys, contractPath)
+		if err != nil {
+			problems = append(problems, MetadataProblem{File: filePath, Line: 1, Message: fmt.Sprintf("no contract %s for this metadata", path.Base(contractPath))})
+		}
+		problems = append(problems, ValidateMetadata(filePath, content, string(contract))...)
+		return nil
+	})
+	return problems, err
+}
+
+// ValidateMetadata reports every problem of a metadata file: fields not allowed by the
+// metadata JSON Schema, reserved or duplicated flags, defaults that are not among the
+// choices or do not match the type, and arguments that the contract never uses. The
+// arguments check is skipped when contract is empty.
+func ValidateMetadata(file string, content []byte, contract string) []MetadataProblem {
+	lines := jsonLines(content)
+	var problems []MetadataProblem
+	report := func(pointer string, format string, args ...interface{}) {
+		problems = append(problems, MetadataProblem{File: file, Line: lineOf(lines, pointer), Message: fmt.Sprintf(format, args...)})
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		line := 1
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line = bytes.Count(content[:syntaxErr.Offset], []byte("\n")) + 1
+		}
+		return []MetadataProblem{{File: file, Line: line, Message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+
+	schema, err := jsschema.CompileString("metadata.schema.json", string(metadataSchema))
+	if err != nil {
+		report("", "cannot compile the metadata schema: %v", err)
+		return problems
+	}
+	if err := schema.Validate(document); err != nil {
+		var validationErr *jsschema.ValidationError
+		if errors.As(err, &validationErr) {
+			for _, leaf := range leafErrors(validationErr) {
+				pointer := leaf.InstanceLocation
+				if strings.HasPrefix(leaf.Message, "additionalProperties") {
+					// point to the first unknown key instead of its object
+					if match := additionalPropertiesRegexp.FindStringSubmatch(leaf.Message); match != nil {
+						pointer += "/" + match[1]
+					}
+				}
+				report(pointer, "%s", leaf.Message)
+			}
+		}
+		// Do not go further on a document with the wrong shape
+		return problems
+	}
+
+	var metadata CommandMetadata
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		report("", "failed to decode metadata: %v", err)
+		return problems
+	}
+
+	inputs := make(map[string]string)
+	for i, arg := range metadata.Arguments {
+		pointer := fmt.Sprintf("/arguments/%d/name", i)
+		name := NormalizeArgumentName(arg.Name)
+		if previous, exists := inputs[name]; exists {
+			report(pointer, "argument %s is already defined by %s", name, previous)
+		}
+		inputs[name] = "argument " + arg.Name
+		if IsVariadicArgument(arg.Name) && i != len(metadata.Arguments)-1 {
+			report(pointer, "only the last argument can be variadic, found %s", arg.Name)
+		}
+		// The optional arguments can be used only by the side files, through ${arg:name}
+		if contract != "" && IsRequiredArgument(arg.Name) && !strings.Contains(contract, "'"+name+"'") {
+			report(pointer, "argument %s does not appear in the contract", name)
+		}
+	}
+
+	shorthands := make(map[string]string)
+	for i, opt := range metadata.Options {
+		pointer := fmt.Sprintf("/options/%d", i)
+		flag, shorthand := parseFlagNames(opt.Name)
+		if reservedFlags[flag] {
+			report(pointer+"/name", "cannot use %s as flag name, it is reserved", flag)
+		}
+		if reservedShorthands[shorthand] {
+			report(pointer+"/name", "cannot use %s as flag shorthand, it is reserved", shorthand)
+		}
+		if previous, exists := inputs[flag]; exists {
+			report(pointer+"/name", "flag --%s is already defined by %s", flag, previous)
+		}
+		inputs[flag] = "option " + opt.Name
+		if shorthand != "" {
+			if previous, exists := shorthands[shorthand]; exists {
+				report(pointer+"/name", "shorthand -%s is already used by %s", shorthand, previous)
+			}
+			shorthands[shorthand] = opt.Name
+		}
+		if opt.Default != "" {
+			if len(opt.Choices) > 0 && !isValidChoice(opt.Default, opt.Choices) {
+				report(pointer+"/default", "default %q is not one of the choices %v", opt.Default, opt.Choices)
+			}
+			if !opt.File {
+				flagType := jsonSchemaType(opt.Type)
+				if flagType == "array" {
+					// array defaults are comma separated elements
+					if _, err := parseItems(ItemsType(opt.Items), strings.Split(opt.Default, ",")); err != nil {
+						report(pointer+"/default", "default does not match the items type: %v", err)
+					}
+				} else if _, err := ParseTypedValue(flagType, opt.Default); err != nil {
+					report(pointer+"/default", "default does not match the type %s: %v", flagType, err)
+				}
+			}
+		}
+		if opt.RawData && !opt.File {
+			report(pointer+"/rawdata", "rawdata has no effect without file")
+		}
+	}
+
+	// The properties of the schema that are neither arguments nor options become flags
+	properties, _ := metadata.Schema["properties"].(map[string]interface{})
+	for name := range properties {
+		if _, exists := inputs[name]; !exists && reservedFlags[name] {
+			report("/schema/properties/"+name, "cannot use the property %s as flag name, it is reserved", name)
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return problems
+}
+
+// parseFlagNames extracts the long name and the shorthand from an option name
+func parseFlagNames(optName string) (flag string, shorthand string) {
+	for _, name := range strings.Split(optName, ", ") {
+		name = strings.TrimSpace(name)
+		if strings.HasPrefix(name, "--") {
+			if parts := strings.Fields(strings.TrimPrefix(name, "--")); len(parts) > 0 {
+				flag = parts[0]
+			}
+		} else if strings.HasPrefix(name, "-") {
+			if parts := strings.Fields(strings.TrimPrefix(name, "-")); len(parts) > 0 {
+				shorthand = parts[0]
+			}
+		}
+	}
+	return flag, shorthand
+}
+
+type leafError struct {
+	InstanceLocation string
+	Message          string
+}
+
+// leafErrors flattens a validation error into its most specific causes
+func leafErrors(err *jsschema.ValidationError) []leafError {
+	if len(err.Causes) == 0 {
+		return []leafError{{InstanceLocation: err.InstanceLocation, Message: err.Message}}
+	}
+	var leaves []leafError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, leafErrors(cause)...)
+	}
+	return leaves
+}
+
+// jsonLines maps the JSON pointer of every value of a JSON document to its line
+func jsonLines(content []byte) map[string]int {
+	lines := make(map[string]int)
+	dec := json.NewDecoder(bytes.NewReader(content))
+	lineAt := func() int {
+		return bytes.Count(content[:dec.InputOffset()], []byte("\n")) + 1
+	}
+
+	var walk func(pointer string) error
+	walk = func(pointer string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if _, exists := lines[pointer]; !exists {
+			lines[pointer] = lineAt()
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := keyTok.(string)
+				child := pointer + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+				lines[child] = lineAt()
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(pointer + "/" + strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	_ = walk("")
+	return lines
+}
+
+// lineOf returns the line of a JSON pointer, or of its closest known parent
+func lineOf(lines map[string]int, pointer string) int {
+	for {
+		if line, ok := lines[pointer]; ok {
+			return line
+		}
+		if pointer == "" {
+			return 1
+		}
+		pointer = pointer[:strings.LastIndex(pointer, "/")]
+	}
+}
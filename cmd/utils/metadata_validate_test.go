@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	contract := `Given I have a 'string' named 'username'
+Then print the data`
+
+	t.Run("Valid metadata", func(t *testing.T) {
+		content := `{
+    "description": "valid",
+    "arguments": [{"name": "<username>"}],
+    "options": [{"name": "-n, --name <name>", "default": "small", "choices": ["small", "large"]}]
+}`
+		if problems := ValidateMetadata("valid.metadata.json", []byte(content), contract); len(problems) != 0 {
+			t.Errorf("Expected no problems, got %v", problems)
+		}
+	})
+
+	t.Run("Unknown fields", func(t *testing.T) {
+		content := `{
+    "description": "unknown",
+    "options": [
+        {
+            "name": "--name",
+            "defualt": "x"
+        }
+    ]
+}`
+		problems := ValidateMetadata("unknown.metadata.json", []byte(content), contract)
+		if len(problems) != 1 {
+			t.Fatalf("Expected 1 problem, got %v", problems)
+		}
+		if problems[0].Line != 6 || !strings.Contains(problems[0].Message, "defualt") {
+			t.Errorf("Expected the unknown field at line 6, got %v", problems[0])
+		}
+	})
+
+	t.Run("Semantic problems", func(t *testing.T) {
+		content := `{
+    "arguments": [
+        {"name": "<username>"},
+        {"name": "<missing>"}
+    ],
+    "options": [
+        {"name": "--help"},
+        {"name": "-h, --host"},
+        {"name": "-n, --name"},
+        {"name": "-n, --number", "type": "integer", "default": "ten"},
+        {"name": "--username"},
+        {"name": "--drink", "default": "huge", "choices": ["small", "large"]}
+    ]
+}`
+		problems := ValidateMetadata("semantic.metadata.json", []byte(content), contract)
+		expected := []string{
+			"semantic.metadata.json:4: argument missing does not appear in the contract",
+			"semantic.metadata.json:7: cannot use help as flag name, it is reserved",
+			"semantic.metadata.json:8: cannot use h as flag shorthand, it is reserved",
+			"semantic.metadata.json:10: shorthand -n is already used by -n, --name",
+			`semantic.metadata.json:10: default does not match the type integer: "ten" is not an integer`,
+			"semantic.metadata.json:11: flag --username is already defined by argument <username>",
+			`semantic.metadata.json:12: default "huge" is not one of the choices [small large]`,
+		}
+		if len(problems) != len(expected) {
+			t.Fatalf("Expected %d problems, got %v", len(expected), problems)
+		}
+		for i, problem := range problems {
+			if problem.String() != expected[i] {
+				t.Errorf("Expected %q, got %q", expected[i], problem.String())
+			}
+		}
+	})
+
+	t.Run("Shadowed root flags and optional arguments", func(t *testing.T) {
+		content := `{"arguments": [{"name": "<username>"}, {"name": "[password]"}], "options": [{"name": "-p, --port <number>"}]}`
+		if problems := ValidateMetadata("shadow.metadata.json", []byte(content), contract); len(problems) != 0 {
+			t.Errorf("Expected no problems, got %v", problems)
+		}
+	})
+
+	t.Run("Reserved schema property", func(t *testing.T) {
+		content := `{"schema": {"type": "object", "properties": {"username": {"type": "string"}, "daemon": {"type": "boolean"}}}}`
+		problems := ValidateMetadata("schema.metadata.json", []byte(content), contract)
+		if len(problems) != 1 || !strings.Contains(problems[0].Message, "property daemon") {
+			t.Errorf("Expected the reserved property to be reported, got %v", problems)
+		}
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		problems := ValidateMetadata("invalid.metadata.json", []byte("{\n  \"description\": \"x\",\n}"), contract)
+		if len(problems) != 1 || problems[0].Line != 3 {
+			t.Errorf("Expected a JSON error at line 3, got %v", problems)
+		}
+	})
+}
+
+func TestValidateMetadataFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"contracts/ok.slang":             {Data: []byte(`Given I have a 'string' named 'user'`)},
+		"contracts/ok.metadata.json":     {Data: []byte(`{"arguments": [{"name": "<user>"}]}`)},
+		"contracts/orphan.metadata.json": {Data: []byte(`{"description": "no contract"}`)},
+	}
+	problems, err := ValidateMetadataFS(fsys, "contracts")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(problems) != 1 || problems[0].File != "contracts/orphan.metadata.json" {
+		t.Errorf("Expected only the orphan metadata to be reported, got %v", problems)
+	}
+}
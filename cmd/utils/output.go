@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// OutputOptions describes how the output of a contract is printed
+type OutputOptions struct {
+	// Format is one of json (default), pretty, yaml or raw
+	Format string
+	// Select is a JSON pointer, like /result/0, or a path expression, like result[0].name,
+	// of the value to print
+	Select string
+	// Raw prints string values without quotes
+	Raw bool
+}
+
+// OutputFormats are the values accepted by OutputOptions.Format
+var OutputFormats = []string{"json", "pretty", "yaml", "raw"}
+
+// ValidateOutputFormat checks that format is one of OutputFormats
+func ValidateOutputFormat(format string) error {
+	for _, f := range OutputFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid output %s, use one of %s", format, strings.Join(OutputFormats, ", "))
+}
+
+// FormatOutput formats the output of a contract. Without a selection, the json and raw formats
+// return the output as zenroom printed it.
+func FormatOutput(output string, opts OutputOptions) ([]byte, error) {
+	if opts.Format == "raw" {
+		if opts.Select != "" {
+			return nil, fmt.Errorf("cannot select a value from the raw output")
+		}
+		return []byte(output), nil
+	}
+	if opts.Select == "" && !opts.Raw && (opts.Format == "" || opts.Format == "json") {
+		return []byte(output), nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(output), &value); err != nil {
+		return nil, fmt.Errorf("the output is not valid JSON: %w", err)
+	}
+	if opts.Select != "" {
+		selected, err := SelectValue(value, opts.Select)
+		if err != nil {
+			return nil, err
+		}
+		value = selected
+	}
+	if str, ok := value.(string); ok && opts.Raw {
+		return []byte(str), nil
+	}
+
+	switch opts.Format {
+	case "pretty":
+		return ToJSON(value)
+	case "yaml":
+		document, err := ToYAML(value)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strings.TrimSuffix(string(document), "\n")), nil
+	default:
+		return json.Marshal(value)
+	}
+}
+
+// pathTokenRegexp matches the keys and the indexes of a path expression
+var pathTokenRegexp = regexp.MustCompile(`([^.\[\]]+)|\[(\d+)\]`)
+
+// SelectValue returns the value of a JSON document found at expr. Expressions starting with a
+// slash are JSON pointers (RFC 6901), the others are paths of keys and indexes like a.b[0].c.
+func SelectValue(value interface{}, expr string) (interface{}, error) {
+	var tokens []string
+	if strings.HasPrefix(expr, "/") {
+		for _, token := range strings.Split(expr[1:], "/") {
+			tokens = append(tokens, strings.NewReplacer("~1", "/", "~0", "~").Replace(token))
+		}
+	} else {
+		rest := strings.NewReplacer(".", "", "[", "", "]", "").Replace(pathTokenRegexp.ReplaceAllString(expr, ""))
+		if rest != "" {
+			return nil, fmt.Errorf("invalid selection %q", expr)
+		}
+		for _, match := range pathTokenRegexp.FindAllStringSubmatch(expr, -1) {
+			tokens = append(tokens, match[1]+match[2])
+		}
+	}
+
+	current := value
+	for _, token := range tokens {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("selection %q: key %s not found", expr, token)
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("selection %q: invalid index %s", expr, token)
+			}
+			current = v[index]
+		default:
+			return nil, fmt.Errorf("selection %q: cannot select %s in a %T", expr, token, current)
+		}
+	}
+	return current, nil
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it, so that
+// readers never see a partially written file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	// Remove the temporary file if anything goes wrong before the rename
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
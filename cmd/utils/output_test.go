@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatOutput(t *testing.T) {
+	output := `{"result":[{"name":"alice","age":30}],"message":"hello"}`
+	tests := []struct {
+		name     string
+		opts     OutputOptions
+		expected string
+		wantErr  bool
+	}{
+		{name: "Default", opts: OutputOptions{}, expected: output},
+		{name: "JSON", opts: OutputOptions{Format: "json"}, expected: output},
+		{name: "Raw", opts: OutputOptions{Format: "raw"}, expected: output},
+		{name: "Pretty", opts: OutputOptions{Format: "pretty", Select: "/result/0"}, expected: "{\n  \"age\": 30,\n  \"name\": \"alice\"\n}"},
+		{name: "YAML", opts: OutputOptions{Format: "yaml", Select: "result[0]"}, expected: "age: 30\nname: alice"},
+		{name: "Quoted string", opts: OutputOptions{Format: "json", Select: "message"}, expected: `"hello"`},
+		{name: "Raw string", opts: OutputOptions{Format: "json", Select: "/message", Raw: true}, expected: "hello"},
+		{name: "Raw number", opts: OutputOptions{Select: "result[0].age", Raw: true}, expected: "30"},
+		{name: "Missing key", opts: OutputOptions{Select: "/missing"}, wantErr: true},
+		{name: "Select raw output", opts: OutputOptions{Format: "raw", Select: "/message"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := FormatOutput(output, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error: %v, got: %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && string(result) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSelectValue(t *testing.T) {
+	value := map[string]interface{}{
+		"a/b": "slash",
+		"list": []interface{}{
+			map[string]interface{}{"key": "value"},
+		},
+	}
+	tests := []struct {
+		expr     string
+		expected interface{}
+		wantErr  bool
+	}{
+		{expr: "/a~1b", expected: "slash"},
+		{expr: "/list/0/key", expected: "value"},
+		{expr: "list[0].key", expected: "value"},
+		{expr: "list[1]", wantErr: true},
+		{expr: "list.key", wantErr: true},
+		{expr: "/list/0/key/more", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			result, err := SelectValue(value, tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error: %v, got: %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "output.json")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := WriteFileAtomic(path, []byte("new"), 0600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != "new" {
+		t.Errorf("Expected the new content, got %q (%v)", content, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected no temporary file left, got %v (%v)", entries, err)
+	}
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "output.json"), []byte("new"), 0600); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,300 @@
+package utils
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+)
+
+// minRedactedLength is the length of the shortest value that is redacted wherever it appears,
+// shorter values would redact too much of the text: they are redacted only as the value of their
+// field in JSON
+const minRedactedLength = 4
+
+// Redactor replaces the values of the secrets in the text written to the logs, to the error
+// messages and to the responses. The zero value is ready to use.
+type Redactor struct {
+	mu     sync.RWMutex
+	values []string
+	// fields match the JSON members of the secrets shorter than minRedactedLength
+	fields []*regexp.Regexp
+}
+
+// NewInputRedactor returns a redactor of the keys of the input, all of them, of the data fields
+// whose name looks secret at any depth, see IsSecretName, and of the values of the data at the secret paths,
+// see SecretPaths
+func NewInputRedactor(input slangroom.SlangroomInput, secretPaths []string) *Redactor {
+	r := &Redactor{}
+	r.AddInput(input, secretPaths)
+	return r
+}
+
+// AddInput adds the keys of the input, the data fields redacted by inspect and the values of the
+// data at the secret paths
+func (r *Redactor) AddInput(input slangroom.SlangroomInput, secretPaths []string) {
+	var keys interface{}
+	if input.Keys != "" && json.Unmarshal([]byte(input.Keys), &keys) == nil {
+		r.Add(keys)
+	}
+	var data interface{}
+	if input.Data == "" || json.Unmarshal([]byte(input.Data), &data) != nil {
+		return
+	}
+	visitSecretFields(data, r.add)
+	for _, secretPath := range secretPaths {
+		path := strings.Split(secretPath, ".")
+		for _, value := range valuesAt(data, path) {
+			r.add(path[len(path)-1], value)
+		}
+	}
+}
+
+// Add adds the string and number leaves of value to the redacted values, the leaves of an object
+// are also redacted by the name of their field whatever their length
+func (r *Redactor) Add(value interface{}) {
+	r.add("", value)
+}
+
+// add adds the leaves of value, name is the field of value or of the array that holds it
+func (r *Redactor) add(name string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			r.add(key, item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			r.add(name, item)
+		}
+	case string, float64, json.Number:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return
+		}
+		text, ok := v.(string)
+		if !ok {
+			text = string(encoded)
+		}
+		if len(text) >= minRedactedLength {
+			r.addString(text)
+		} else if name != "" {
+			r.addField(name, string(encoded))
+		}
+	}
+}
+
+// addField redacts a short value where it is the value of the field name in JSON, encoded is the
+// JSON encoding of the value
+func (r *Redactor) addField(name string, encoded string) {
+	encodedName, err := json.Marshal(name)
+	if err != nil {
+		return
+	}
+	// A number must not be followed by another digit, the strings end with their quote
+	pattern := regexp.MustCompile(`(` + regexp.QuoteMeta(string(encodedName)) + `\s*:\s*)` + regexp.QuoteMeta(encoded) + `([^0-9.eE+-]|$)`)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fields = append(r.fields, pattern)
+}
+
+func (r *Redactor) addString(value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values = append(r.values, value)
+	if encoded, err := json.Marshal(value); err == nil && string(encoded[1:len(encoded)-1]) != value {
+		// The value as it appears inside a JSON string
+		r.values = append(r.values, string(encoded[1:len(encoded)-1]))
+	}
+	// The longest values are replaced first, a value can contain a shorter one
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+}
+
+// Redact replaces the redacted values in text
+func (r *Redactor) Redact(text string) string {
+	if r == nil {
+		return text
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, value := range r.values {
+		text = strings.ReplaceAll(text, value, Redacted)
+	}
+	for _, field := range r.fields {
+		text = field.ReplaceAllString(text, `${1}"`+Redacted+`"${2}`)
+	}
+	return text
+}
+
+// RedactValue returns a copy of a JSON value with the redacted values replaced
+func (r *Redactor) RedactValue(value interface{}) interface{} {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return Redacted
+	}
+	var redacted interface{}
+	if err := json.Unmarshal([]byte(r.Redact(string(encoded))), &redacted); err != nil {
+		return Redacted
+	}
+	return redacted
+}
+
+// RedactError returns a copy of an execution error with the redacted values replaced in its
+// message, heap, trace and logs
+func (r *Redactor) RedactError(execErr *ExecutionError) *ExecutionError {
+	redacted := *execErr
+	redacted.Message = r.Redact(execErr.Message)
+	redacted.Statement = r.Redact(execErr.Statement)
+	redacted.Logs = r.Redact(execErr.Logs)
+	if execErr.Heap != nil {
+		redacted.Heap = r.RedactValue(execErr.Heap)
+	}
+	if execErr.Trace != nil {
+		redacted.Trace = make([]string, len(execErr.Trace))
+		for i, line := range execErr.Trace {
+			redacted.Trace[i] = r.Redact(line)
+		}
+	}
+	return &redacted
+}
+
+// SecretPaths returns the dotted paths of the data marked secret in the metadata: the arguments
+// and the options with secret: true and the properties with "secret": true of their properties,
+// or of the schema of the metadata
+func SecretPaths(metadata *CommandMetadata) []string {
+	if metadata == nil {
+		return nil
+	}
+	var paths []string
+	for _, arg := range metadata.Arguments {
+		name := NormalizeArgumentName(arg.Name)
+		if arg.Secret {
+			paths = append(paths, name)
+			continue
+		}
+		paths = append(paths, secretProperties(name, arg.Properties)...)
+	}
+	for _, opt := range metadata.Options {
+		name := GetFlagName(opt.Name)
+		if opt.Secret {
+			paths = append(paths, name)
+			continue
+		}
+		paths = append(paths, secretProperties(name, opt.Properties)...)
+	}
+	if properties, ok := metadata.Schema["properties"].(map[string]interface{}); ok {
+		paths = append(paths, secretProperties("", properties)...)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// secretProperties returns the paths of the properties marked secret, prefixed with prefix
+func secretProperties(prefix string, properties map[string]interface{}) []string {
+	var paths []string
+	for name, property := range properties {
+		propertyPath := name
+		if prefix != "" {
+			propertyPath = prefix + "." + name
+		}
+		schema, ok := property.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if secret, _ := schema["secret"].(bool); secret {
+			paths = append(paths, propertyPath)
+			continue
+		}
+		if nested, ok := schema["properties"].(map[string]interface{}); ok {
+			paths = append(paths, secretProperties(propertyPath, nested)...)
+		}
+	}
+	return paths
+}
+
+// valuesAt returns the values found at path in a JSON value, the arrays met along the path are
+// walked item by item
+func valuesAt(value interface{}, path []string) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		var values []interface{}
+		for _, item := range v {
+			values = append(values, valuesAt(item, path)...)
+		}
+		return values
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return []interface{}{v}
+		}
+		item, ok := v[path[0]]
+		if !ok {
+			return nil
+		}
+		return valuesAt(item, path[1:])
+	default:
+		if len(path) == 0 {
+			return []interface{}{v}
+		}
+		return nil
+	}
+}
+
+// visitSecretFields calls visit with the name and the value of the fields whose name looks secret,
+// at any depth
+func visitSecretFields(value interface{}, visit func(name string, value interface{})) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if IsSecretName(key) {
+				visit(key, item)
+				continue
+			}
+			visitSecretFields(item, visit)
+		}
+	case []interface{}:
+		for _, item := range v {
+			visitSecretFields(item, visit)
+		}
+	}
+}
+
+// redactSecretFields replaces the values of the fields whose name looks secret, at any depth
+func redactSecretFields(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if IsSecretName(key) {
+				v[key] = redactAll(item)
+				continue
+			}
+			redactSecretFields(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			redactSecretFields(item)
+		}
+	}
+}
+
+// redactPath replaces the values found at path in a JSON value, like valuesAt finds them
+func redactPath(value interface{}, path []string) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			redactPath(item, path)
+		}
+	case map[string]interface{}:
+		item, ok := v[path[0]]
+		if !ok {
+			return
+		}
+		if len(path) == 1 {
+			v[path[0]] = redactAll(item)
+			return
+		}
+		redactPath(item, path[1:])
+	}
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+)
+
+func TestSecretPaths(t *testing.T) {
+	metadata := &CommandMetadata{
+		Arguments: []ArgumentMetadata{{Name: "<pin>", Secret: true}, {Name: "[name]"}},
+		Options: []OptionMetadata{
+			{Name: "-t, --api_token <token>", Secret: true},
+			{Name: "--account <account>", Type: "object", Properties: map[string]interface{}{
+				"iban":   map[string]interface{}{"type": "string", "secret": true},
+				"holder": map[string]interface{}{"type": "string"},
+			}},
+		},
+		Schema: map[string]interface{}{"properties": map[string]interface{}{
+			"wallet": map[string]interface{}{"properties": map[string]interface{}{
+				"seed": map[string]interface{}{"type": "string", "secret": true},
+			}},
+		}},
+	}
+	expected := []string{"account.iban", "api_token", "pin", "wallet.seed"}
+	if paths := SecretPaths(metadata); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected %v, got %v", expected, paths)
+	}
+	if paths := SecretPaths(nil); paths != nil {
+		t.Errorf("Expected no paths without metadata, got %v", paths)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	redactor := NewInputRedactor(slangroom.SlangroomInput{
+		Data: `{"accounts": [{"iban": "IT60X0542811101000000123456"}, {"iban": "DE89370400440532013000"}], "pin": "12345", "card": {"pin": "42"}, "code": 7, "otp": 123, "name": "alice", "password": "hunter2-password", "api_token": {"value": "tok-123456"}}`,
+		Keys: `{"keyring": {"eddsa": "EDDSA \"QUOTED\" KEY"}, "short": "abc"}`,
+	}, []string{"accounts.iban", "pin", "card.pin", "otp", "missing.path"})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "iban IT60X0542811101000000123456 and DE89370400440532013000", expected: "iban [REDACTED] and [REDACTED]"},
+		{input: "pin 12345 of alice", expected: "pin [REDACTED] of alice"},
+		{input: `key EDDSA "QUOTED" KEY`, expected: "key [REDACTED]"},
+		{input: `{"eddsa":"EDDSA \"QUOTED\" KEY"}`, expected: `{"eddsa":"[REDACTED]"}`},
+		{input: "abc is too short to be redacted", expected: "abc is too short to be redacted"},
+		// The short values are redacted as the value of their field
+		{input: `{"short": "abc", "other": "abc"}`, expected: `{"short": "[REDACTED]", "other": "abc"}`},
+		{input: `{"code":7,"pin":"42"}`, expected: `{"code":7,"pin":"[REDACTED]"}`},
+		// The fields redacted by name by inspect are redacted as well
+		{input: "wrong password hunter2-password", expected: "wrong password [REDACTED]"},
+		{input: "token tok-123456 expired", expected: "token [REDACTED] expired"},
+		{input: "alice logged in", expected: "alice logged in"},
+		{input: `{"otp": 123, "id": 1234}`, expected: `{"otp": "[REDACTED]", "id": 1234}`},
+		{input: `{"otp": 1234}`, expected: `{"otp": 1234}`},
+	}
+	for _, tt := range tests {
+		if redacted := redactor.Redact(tt.input); redacted != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, redacted)
+		}
+	}
+
+	execErr := &ExecutionError{Message: "wrong pin 12345", Trace: []string{"pin 12345"}, Heap: map[string]interface{}{"pin": "12345"}}
+	redacted := redactor.RedactError(execErr)
+	if redacted.Message != "wrong pin [REDACTED]" || redacted.Trace[0] != "pin [REDACTED]" || redacted.Heap.(map[string]interface{})["pin"] != Redacted {
+		t.Errorf("Expected a redacted error, got %+v", redacted)
+	}
+	if execErr.Message != "wrong pin 12345" || execErr.Trace[0] != "pin 12345" {
+		t.Errorf("Expected the original error to be untouched, got %+v", execErr)
+	}
+
+	byName := NewInputRedactor(slangroom.SlangroomInput{Data: `{"seed": "abandon ability able", "wallet": {"private_key": "0xdeadbeef"}}`}, nil)
+	if redacted := byName.Redact("seed abandon ability able"); redacted != "seed [REDACTED]" {
+		t.Errorf("Expected the field redacted by name without secret paths, got %q", redacted)
+	}
+	if redacted := byName.Redact("key 0xdeadbeef"); redacted != "key [REDACTED]" {
+		t.Errorf("Expected the nested field redacted by name, got %q", redacted)
+	}
+
+	var nilRedactor *Redactor
+	if nilRedactor.Redact("pin 12345") != "pin 12345" {
+		t.Errorf("Expected a nil redactor to leave the text as is")
+	}
+}
@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	jsschema "github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/spf13/cobra"
+)
+
+// HasJSONSchema reports whether the metadata declares a JSON Schema, either for the whole
+// input or for at least one argument or option.
+func HasJSONSchema(metadata *CommandMetadata) bool {
+	if metadata == nil {
+		return false
+	}
+	if metadata.Schema != nil {
+		return true
+	}
+	for _, arg := range metadata.Arguments {
+		if arg.Schema != nil {
+			return true
+		}
+	}
+	for _, opt := range metadata.Options {
+		if opt.Schema != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// InputSchema returns the JSON Schema of the contract input described by the metadata.
+// The top level schema is used as is, otherwise an object schema is assembled from the
+// schema of each argument and option, falling back to their type, choices and default.
+// The boolean is false when the metadata declares no JSON Schema at all, in which case
+// callers should use GenerateStruct.
+func InputSchema(metadata *CommandMetadata) (map[string]interface{}, bool) {
+	if !HasJSONSchema(metadata) {
+		return nil, false
+	}
+	if metadata.Schema != nil {
+		return metadata.Schema, true
+	}
+
+	properties := make(map[string]interface{})
+	for _, arg := range metadata.Arguments {
+		name := NormalizeArgumentName(arg.Name)
+		if arg.Schema != nil {
+			properties[name] = arg.Schema
+		} else {
+			properties[name] = legacySchema(ArgumentType(arg), arg.Properties, arg.Items, nil, "")
+		}
+	}
+	for _, opt := range metadata.Options {
+		name := GetFlagName(opt.Name)
+		switch {
+		case opt.Schema != nil:
+			properties[name] = opt.Schema
+		case opt.File && !opt.RawData:
+			properties[name] = map[string]interface{}{"type": "string", "format": "binary"}
+		default:
+			properties[name] = legacySchema(opt.Type, opt.Properties, opt.Items, opt.Choices, opt.Default)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	arguments, options := RequiredInputs(metadata)
+	if required := append(arguments, options...); len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema, true
+}
+
+// CompileJSONSchema compiles a JSON Schema (draft 2020-12 unless it declares a different $schema),
+// the schema is anything that encodes to JSON like the map decoded from metadata.
+func CompileJSONSchema(schema interface{}) (*jsschema.Schema, error) {
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	compiler := jsschema.NewCompiler()
+	compiler.Draft = jsschema.Draft2020
+	if err := compiler.AddResource("schema.json", bytes.NewReader(schemaJSON)); err != nil {
+		return nil, fmt.Errorf("failed to add schema resource: %w", err)
+	}
+	compiledSchema, err := compiler.Compile("schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema: %w", err)
+	}
+	return compiledSchema, nil
+}
+
+// CompileInputSchema compiles the InputSchema of the metadata, so that the CLI validates the input
+// against the same schema as the HTTP server. The schema is nil when the metadata declares no JSON
+// Schema.
+func CompileInputSchema(metadata *CommandMetadata) (*jsschema.Schema, error) {
+	schema, ok := InputSchema(metadata)
+	if !ok {
+		return nil, nil
+	}
+	return CompileJSONSchema(schema)
+}
+
+// ValidateInput validates the data of a contract against its compiled input schema, a nil schema
+// accepts any data. The optional arguments that were not given are left out: in the data they are
+// only empty placeholders.
+func ValidateInput(schema *jsschema.Schema, data string, metadata *CommandMetadata, args []string) error {
+	if schema == nil {
+		return nil
+	}
+	var document interface{} = map[string]interface{}{}
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &document); err != nil {
+			return fmt.Errorf("invalid data: %w", err)
+		}
+	}
+	if object, ok := document.(map[string]interface{}); ok && metadata != nil {
+		for i := len(args); i < len(metadata.Arguments); i++ {
+			name := NormalizeArgumentName(metadata.Arguments[i].Name)
+			if object[name] == "" {
+				delete(object, name)
+			}
+		}
+	}
+	if err := schema.Validate(document); err != nil {
+		return fmt.Errorf("the input does not match the schema of the metadata: %w", err)
+	}
+	return nil
+}
+
+// addSchemaFlags registers a flag for each top level property of the schema of the metadata that
+// is not already an argument or an option, with the type, description, enum and default of the
+// property. The required properties are not required flags: they can come from the side files,
+// the schema reports them when they are missing.
+func addSchemaFlags(cmd *cobra.Command, schema map[string]interface{}, argContents map[string]interface{}, flagContents map[string]FlagData) error {
+	properties, _ := schema["properties"].(map[string]interface{})
+	required := make(map[string]bool)
+	if names, ok := schema["required"].([]interface{}); ok {
+		for _, name := range names {
+			if name, ok := name.(string); ok {
+				required[name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, exists := argContents[name]; exists {
+			continue
+		}
+		if _, exists := flagContents[name]; exists {
+			continue
+		}
+		if reservedFlags[name] {
+			return fmt.Errorf("cannot use the property %s of the schema as flag name, --%s is reserved", name, name)
+		}
+		property, ok := properties[name].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid property %s in the schema", name)
+		}
+		typeStr, _ := property["type"].(string)
+		flagType := jsonSchemaType(typeStr)
+		if flagType == "string" {
+			flagType = ""
+		}
+		description, _ := property["description"].(string)
+		if description == "" {
+			description = fmt.Sprintf("The %s input of the contract", name)
+		}
+		var choices []string
+		if enum, ok := property["enum"].([]interface{}); ok {
+			for _, value := range enum {
+				choices = append(choices, fmt.Sprint(value))
+			}
+			description += fmt.Sprintf(" (Choices: %v)", choices)
+		}
+		if required[name] {
+			description += " (required)"
+		}
+		var def string
+		value, hasDefault := property["default"]
+		if hasDefault {
+			var err error
+			if def, err = schemaDefault(value); err != nil {
+				return fmt.Errorf("invalid default for property %s: %w", name, err)
+			}
+		}
+		if err := addTypedFlag(cmd, name, "", flagType, def, description); err != nil {
+			return err
+		}
+		items, _ := property["items"].(map[string]interface{})
+		flagData := FlagData{
+			Choices:    choices,
+			Type:       flagType,
+			ItemsType:  ItemsType(items),
+			HasDefault: hasDefault,
+		}
+		nested, _ := property["properties"].(map[string]interface{})
+		if flagType == "object" && len(nested) > 0 {
+			var err error
+			if flagData.PropertiesSchema, err = compileProperties(name, nested); err != nil {
+				return err
+			}
+			if err := addPropertyFlags(cmd, name, nil, nested, flagContents); err != nil {
+				return err
+			}
+		}
+		flagContents[name] = flagData
+	}
+	return nil
+}
+
+// schemaDefault converts the default of a schema property into the default value of its flag,
+// the arrays are comma separated and the objects are JSON
+func schemaDefault(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		content, err := json.Marshal(value)
+		return string(content), err
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+// legacySchema converts the type, properties, items, choices and default keys of the metadata
+// into the equivalent JSON Schema.
+func legacySchema(typeStr string, properties, items map[string]interface{}, choices []string, def string) map[string]interface{} {
+	schema := map[string]interface{}{"type": jsonSchemaType(typeStr)}
+	if len(properties) > 0 {
+		schema["type"] = "object"
+		schema["properties"] = properties
+	}
+	if schema["type"] == "array" {
+		if items != nil {
+			schema["items"] = items
+		} else {
+			schema["items"] = map[string]interface{}{"type": "string"}
+		}
+	}
+	if len(choices) > 0 {
+		enum := make([]interface{}, len(choices))
+		for i, choice := range choices {
+			enum[i] = choice
+		}
+		schema["enum"] = enum
+	}
+	if def != "" {
+		schema["default"] = typedDefault(schema["type"].(string), def)
+	}
+	return schema
+}
+
+// jsonSchemaType maps the metadata type names to JSON Schema type names
+func jsonSchemaType(typeStr string) string {
+	switch strings.ToLower(typeStr) {
+	case "integer", "int":
+		return "integer"
+	case "number", "float", "float64":
+		return "number"
+	case "boolean", "bool":
+		return "boolean"
+	case "array", "[]":
+		return "array"
+	case "dictionary", "map", "object":
+		return "object"
+	default:
+		return "string"
+	}
+}
+
+// typedDefault converts a default value from metadata into the JSON type of its schema
+func typedDefault(schemaType string, def string) interface{} {
+	switch schemaType {
+	case "integer":
+		if v, err := strconv.ParseInt(def, 10, 64); err == nil {
+			return v
+		}
+	case "number":
+		if v, err := strconv.ParseFloat(def, 64); err == nil {
+			return v
+		}
+	case "boolean":
+		if v, err := strconv.ParseBool(def); err == nil {
+			return v
+		}
+	}
+	return def
+}
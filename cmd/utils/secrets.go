@@ -0,0 +1,339 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+)
+
+// AgeIdentityEnv is the environment variable with the identity file used to decrypt the age secrets
+const AgeIdentityEnv = "TWINROOM_AGE_IDENTITY"
+
+// TrustSecretsFlag is the flag that lets the contracts of a folder or of a bundle run the command
+// secrets and read the secret files outside of their folder
+const TrustSecretsFlag = "trust-secrets"
+
+// SecretRef is an entry of the secrets of the metadata, it tells which provider reads the secret
+// and where the secret is placed in the keys of the contract.
+type SecretRef struct {
+	// Provider is the name of the provider, one of SecretProviderNames
+	Provider string `json:"provider"`
+	// Key is the dotted path of the secret in the keys, like keyring.eddsa. When it is empty the
+	// secret is a JSON object merged over the whole keys.
+	Key string `json:"key,omitempty"`
+	// Format is json to decode the secret placed at Key, string by default
+	Format string `json:"format,omitempty"`
+	// Env is the environment variable read by the env provider
+	Env string `json:"env,omitempty"`
+	// Path is the file read by the file, age and pgp providers, relative to the contract
+	Path string `json:"path,omitempty"`
+	// Identity is the identity file of the age provider, $TWINROOM_AGE_IDENTITY by default
+	Identity string `json:"identity,omitempty"`
+	// Command is the program and the arguments run by the command provider, without a shell
+	Command []string `json:"command,omitempty"`
+}
+
+// String describes where the secret comes from, without its value
+func (ref SecretRef) String() string {
+	var from string
+	switch ref.Provider {
+	case "env":
+		from = "env " + ref.Env
+	case "command":
+		from = "command " + strings.Join(ref.Command, " ")
+	default:
+		from = ref.Provider + " " + ref.Path
+	}
+	if ref.Key != "" {
+		from += " (" + ref.Key + ")"
+	}
+	return "secret " + from
+}
+
+// SecretOptions tells LoadSecrets where the secrets of a contract are read
+type SecretOptions struct {
+	// Dir is the folder of the relative paths of the secrets
+	Dir string
+	// Trusted lets the secrets run commands and read the files outside of Dir. Only the contracts
+	// embedded at build time are trusted, the ones of a folder or of a bundle need an opt-in.
+	Trusted bool
+	// Redactor gets the values of the secrets, so that they never reach the logs. It can be nil.
+	Redactor *Redactor
+}
+
+// SecretProvider reads a secret described by a SecretRef of the metadata, the relative paths are
+// resolved from dir.
+type SecretProvider interface {
+	Secret(ref SecretRef, dir string) ([]byte, error)
+}
+
+// SecretProviderFunc adapts a function to a SecretProvider
+type SecretProviderFunc func(ref SecretRef, dir string) ([]byte, error)
+
+// Secret calls f
+func (f SecretProviderFunc) Secret(ref SecretRef, dir string) ([]byte, error) {
+	return f(ref, dir)
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{
+		"env":     SecretProviderFunc(envSecret),
+		"file":    SecretProviderFunc(fileSecret),
+		"age":     SecretProviderFunc(ageSecret),
+		"pgp":     SecretProviderFunc(pgpSecret),
+		"command": SecretProviderFunc(commandSecret),
+	}
+)
+
+// RegisterSecretProvider makes a provider available to the metadata under name, replacing the
+// provider with the same name
+func RegisterSecretProvider(name string, provider SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders[name] = provider
+}
+
+// SecretProviderNames returns the names of the registered providers
+func SecretProviderNames() []string {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+	names := make([]string, 0, len(secretProviders))
+	for name := range secretProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SecretRefs returns the secrets of the metadata, keys_command is a command secret for the
+// whole keys
+func SecretRefs(metadata *CommandMetadata) []SecretRef {
+	if metadata == nil {
+		return nil
+	}
+	refs := make([]SecretRef, 0, len(metadata.Secrets)+1)
+	if len(metadata.KeysCommand) > 0 {
+		refs = append(refs, SecretRef{Provider: "command", Command: metadata.KeysCommand})
+	}
+	return append(refs, metadata.Secrets...)
+}
+
+// LoadSecrets reads the secrets and places them in the keys of the input, over the keys of the
+// side files. The values are added to the redactor of the options, and the sources of the keys are updated with the providers, never with the values.
+func LoadSecrets(refs []SecretRef, opts SecretOptions, input *slangroom.SlangroomInput, sources *InputSources) error {
+	for _, ref := range refs {
+		secretProvidersMu.RLock()
+		provider, ok := secretProviders[ref.Provider]
+		secretProvidersMu.RUnlock()
+		if !ok {
+			return fmt.Errorf("unknown secret provider %q, use one of %s", ref.Provider, strings.Join(SecretProviderNames(), ", "))
+		}
+		if !opts.Trusted {
+			if err := checkUntrustedSecret(ref, opts.Dir); err != nil {
+				return fmt.Errorf("cannot read %s: %w", ref, err)
+			}
+		}
+		secret, err := provider.Secret(ref, opts.Dir)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", ref, err)
+		}
+		overlay, err := secretOverlay(ref, secret)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", ref, err)
+		}
+		if opts.Redactor != nil {
+			opts.Redactor.Add(overlay)
+		}
+		content, err := json.Marshal(overlay)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", ref, err)
+		}
+		if input.Keys, err = Merge(input.Keys, string(content), MergeOptions{Mode: MergeDeep}); err != nil {
+			return fmt.Errorf("failed to merge %s: %w", ref, err)
+		}
+		if sources != nil {
+			if sources.Keys != "" {
+				sources.Keys += ", "
+			}
+			sources.Keys += ref.String()
+		}
+	}
+	return nil
+}
+
+// checkUntrustedSecret returns an error when the secret of a contract that is not trusted runs a
+// command or reads a file outside of dir, following the symbolic links
+func checkUntrustedSecret(ref SecretRef, dir string) error {
+	if ref.Provider == "command" {
+		return fmt.Errorf("only the embedded contracts run commands, use --%s to run it", TrustSecretsFlag)
+	}
+	for _, secretPath := range []string{ref.Path, ref.Identity} {
+		if secretPath == "" {
+			continue
+		}
+		outside := fmt.Errorf("%s is outside of the folder of the contract, use --%s to read it", secretPath, TrustSecretsFlag)
+		if secretPath == "~" || strings.HasPrefix(secretPath, "~/") || !filepath.IsLocal(secretPath) {
+			return outside
+		}
+		resolved, err := filepath.EvalSymlinks(filepath.Join(dir, secretPath))
+		if err != nil {
+			// The provider reports the missing files
+			continue
+		}
+		root, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return err
+		}
+		if rel, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(rel) {
+			return outside
+		}
+	}
+	return nil
+}
+
+// secretOverlay returns the JSON object with the secret placed at the key of ref
+func secretOverlay(ref SecretRef, secret []byte) (map[string]interface{}, error) {
+	if ref.Key == "" {
+		var keys map[string]interface{}
+		if err := json.Unmarshal(secret, &keys); err != nil {
+			return nil, fmt.Errorf("the keys must be a JSON object: %w", err)
+		}
+		return keys, nil
+	}
+	var value interface{} = strings.TrimRight(string(secret), "\r\n")
+	switch ref.Format {
+	case "", "string":
+	case "json":
+		if err := json.Unmarshal(secret, &value); err != nil {
+			return nil, fmt.Errorf("the secret is not valid JSON: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q, use string or json", ref.Format)
+	}
+	parts := strings.Split(ref.Key, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		value = map[string]interface{}{parts[i]: value}
+	}
+	return map[string]interface{}{parts[0]: value}, nil
+}
+
+func envSecret(ref SecretRef, _ string) ([]byte, error) {
+	if ref.Env == "" {
+		return nil, fmt.Errorf("missing env")
+	}
+	value, ok := os.LookupEnv(ref.Env)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", ref.Env)
+	}
+	return []byte(value), nil
+}
+
+func fileSecret(ref SecretRef, dir string) ([]byte, error) {
+	secretPath, err := secretFilePath(ref.Path, dir)
+	if err != nil {
+		return nil, err
+	}
+	return readPrivateFile(secretPath)
+}
+
+func ageSecret(ref SecretRef, dir string) ([]byte, error) {
+	secretPath, err := secretFilePath(ref.Path, dir)
+	if err != nil {
+		return nil, err
+	}
+	identity := ref.Identity
+	if identity == "" {
+		identity = os.Getenv(AgeIdentityEnv)
+	}
+	if identity == "" {
+		return nil, fmt.Errorf("missing identity, set it in the metadata or with %s", AgeIdentityEnv)
+	}
+	if identity, err = secretFilePath(identity, dir); err != nil {
+		return nil, err
+	}
+	if _, err := readPrivateFile(identity); err != nil {
+		return nil, err
+	}
+	return runSecretCommand([]string{"age", "--decrypt", "--identity", identity, secretPath})
+}
+
+func pgpSecret(ref SecretRef, dir string) ([]byte, error) {
+	secretPath, err := secretFilePath(ref.Path, dir)
+	if err != nil {
+		return nil, err
+	}
+	return runSecretCommand([]string{"gpg", "--batch", "--quiet", "--decrypt", secretPath})
+}
+
+func commandSecret(ref SecretRef, _ string) ([]byte, error) {
+	if len(ref.Command) == 0 {
+		return nil, fmt.Errorf("missing command")
+	}
+	return runSecretCommand(ref.Command)
+}
+
+// runSecretCommand runs a command and returns its output. The standard error is passed through,
+// so that the command can ask for a passphrase, and the output is never part of the errors.
+func runSecretCommand(command []string) ([]byte, error) {
+	// #nosec G204 -- the command comes from the metadata of the contract and runs without a shell
+	cmd := exec.Command(command[0], command[1:]...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w", command[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// secretFilePath resolves the path of a secret file, ~ is the home directory and a relative
+// path starts from dir
+func secretFilePath(secretPath string, dir string) (string, error) {
+	if secretPath == "" {
+		return "", fmt.Errorf("missing path")
+	}
+	if secretPath == "~" || strings.HasPrefix(secretPath, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, secretPath[1:]), nil
+	}
+	if filepath.IsAbs(secretPath) {
+		return secretPath, nil
+	}
+	return filepath.Join(dir, secretPath), nil
+}
+
+// readPrivateFile reads a file that only its owner can read or write, like ssh does for the
+// private keys. The permissions are checked on the opened file, so that the file that is read is
+// the one that is checked. The permissions are not checked on Windows.
+func readPrivateFile(filePath string) ([]byte, error) {
+	file, err := os.Open(filePath) // #nosec G304 -- the path comes from the metadata of the contract
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", filePath)
+	}
+	if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm&0o077 != 0 {
+		return nil, fmt.Errorf("permissions %04o for %s are too open, it must be readable only by its owner (chmod 600)", perm, filePath)
+	}
+	return io.ReadAll(file)
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+)
+
+func TestLoadSecrets(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "eddsa"), []byte("EDDSA-SECRET-KEY\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+	t.Setenv("TWINROOM_TEST_KEYS", `{"keyring": {"ecdh": "ECDH-SECRET-KEY"}, "issuer": "alice"}`)
+
+	input := slangroom.SlangroomInput{Keys: `{"keyring": {"ecdh": "plaintext"}, "other": 1}`}
+	sources := InputSources{Keys: "hello.keys.json"}
+	refs := []SecretRef{
+		{Provider: "env", Env: "TWINROOM_TEST_KEYS"},
+		{Provider: "file", Path: "eddsa", Key: "keyring.eddsa"},
+	}
+	redactor := &Redactor{}
+	if err := LoadSecrets(refs, SecretOptions{Dir: dir, Redactor: redactor}, &input, &sources); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"issuer":"alice","keyring":{"ecdh":"ECDH-SECRET-KEY","eddsa":"EDDSA-SECRET-KEY"},"other":1}`
+	if input.Keys != expected {
+		t.Errorf("Expected keys %s, got %s", expected, input.Keys)
+	}
+	if sources.Keys != "hello.keys.json, secret env TWINROOM_TEST_KEYS, secret file eddsa (keyring.eddsa)" {
+		t.Errorf("Unexpected sources %s", sources.Keys)
+	}
+	redacted := redactor.Redact("signed with EDDSA-SECRET-KEY and ECDH-SECRET-KEY")
+	if redacted != "signed with [REDACTED] and [REDACTED]" {
+		t.Errorf("Expected the secrets to be redacted, got %s", redacted)
+	}
+
+	errorTests := []struct {
+		name     string
+		ref      SecretRef
+		expected string
+	}{
+		{name: "Unknown provider", ref: SecretRef{Provider: "vault"}, expected: `unknown secret provider "vault"`},
+		{name: "Missing variable", ref: SecretRef{Provider: "env", Env: "TWINROOM_TEST_MISSING"}, expected: "is not set"},
+		{name: "Not an object", ref: SecretRef{Provider: "file", Path: "eddsa"}, expected: "must be a JSON object"},
+		{name: "Missing file", ref: SecretRef{Provider: "file", Path: "missing", Key: "k"}, expected: "missing"},
+		{name: "Missing identity", ref: SecretRef{Provider: "age", Path: "keys.age"}, expected: AgeIdentityEnv},
+		{name: "Absolute path", ref: SecretRef{Provider: "file", Path: filepath.Join(dir, "eddsa"), Key: "k"}, expected: "outside of the folder"},
+		{name: "Home path", ref: SecretRef{Provider: "file", Path: "~/eddsa", Key: "k"}, expected: "outside of the folder"},
+		{name: "Parent path", ref: SecretRef{Provider: "file", Path: "../eddsa", Key: "k"}, expected: "--" + TrustSecretsFlag},
+		{name: "Outside identity", ref: SecretRef{Provider: "age", Path: "keys.age", Identity: "/etc/age.key"}, expected: "outside of the folder"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(AgeIdentityEnv, "")
+			err := LoadSecrets([]SecretRef{tt.ref}, SecretOptions{Dir: dir}, &slangroom.SlangroomInput{}, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %s, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestTrustedSecrets(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "eddsa"), []byte("OUTSIDE-SECRET-KEY"), 0600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.Symlink(filepath.Join(outside, "eddsa"), filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symbolic links not available: %v", err)
+	}
+	refs := []SecretRef{{Provider: "file", Path: "link", Key: "eddsa"}}
+	var input slangroom.SlangroomInput
+	if err := LoadSecrets(refs, SecretOptions{Dir: dir}, &input, nil); err == nil || !strings.Contains(err.Error(), "outside of the folder") {
+		t.Errorf("Expected a link outside of the folder to be rejected, got %v", err)
+	}
+	if err := LoadSecrets(refs, SecretOptions{Dir: dir, Trusted: true}, &input, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if input.Keys != `{"eddsa":"OUTSIDE-SECRET-KEY"}` {
+		t.Errorf("Unexpected keys %s", input.Keys)
+	}
+}
+
+func TestReadPrivateFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the permissions are not checked on Windows")
+	}
+	secretPath := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(secretPath, []byte(`{}`), 0600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+	if _, err := readPrivateFile(secretPath); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := os.Chmod(secretPath, 0644); err != nil {
+		t.Fatalf("Failed to change permissions: %v", err)
+	}
+	if _, err := readPrivateFile(secretPath); err == nil || !strings.Contains(err.Error(), "too open") {
+		t.Errorf("Expected an error for a file readable by others, got %v", err)
+	}
+}
+
+func TestCommandSecret(t *testing.T) {
+	if _, err := exec.LookPath("echo"); err != nil {
+		t.Skip("echo not available")
+	}
+	metadata := &CommandMetadata{KeysCommand: []string{"echo", `{"keyring": {"eddsa": "COMMAND-SECRET-KEY"}}`}}
+	var input slangroom.SlangroomInput
+	if err := LoadSecrets(SecretRefs(metadata), SecretOptions{Dir: "."}, &input, nil); err == nil || !strings.Contains(err.Error(), "only the embedded contracts") {
+		t.Errorf("Expected the command to be rejected for a contract that is not trusted, got %v", err)
+	}
+	if err := LoadSecrets(SecretRefs(metadata), SecretOptions{Dir: ".", Trusted: true}, &input, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if input.Keys != `{"keyring":{"eddsa":"COMMAND-SECRET-KEY"}}` {
+		t.Errorf("Unexpected keys %s", input.Keys)
+	}
+	failing := []SecretRef{{Provider: "command", Command: []string{"twinroom-missing-command"}}}
+	if err := LoadSecrets(failing, SecretOptions{Dir: ".", Trusted: true}, &input, nil); err == nil {
+		t.Errorf("Expected an error for a missing command")
+	}
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+)
+
+// ProfileEnv is the environment variable that selects the profile when --profile is not given
+const ProfileEnv = "TWINROOM_PROFILE"
+
+// ValidateProfile checks that a profile can be used in the name of a file or of a directory
+func ValidateProfile(profile string) error {
+	if profile == "." || profile == ".." || strings.ContainsAny(profile, `/\`) {
+		return fmt.Errorf("invalid profile %q", profile)
+	}
+	return nil
+}
+
+// sideFilePaths returns the side files of a field of the input that exist in dir: the base
+// file, <filename>.<field>.json, followed by the overlays of the profile, first
+// <filename>.<field>.<profile>.json and then profiles/<profile>/<filename>.<field>.json. Each file
+// can also be written in JSON5 or YAML, see FindSideFile.
+func sideFilePaths(fsys fs.FS, dir string, filename string, field string, profile string) ([]string, error) {
+	stems := []string{path.Join(dir, fmt.Sprintf("%s.%s", filename, field))}
+	if profile != "" {
+		stems = append(stems,
+			path.Join(dir, fmt.Sprintf("%s.%s.%s", filename, field, profile)),
+			path.Join(dir, "profiles", profile, fmt.Sprintf("%s.%s", filename, field)),
+		)
+	}
+	var paths []string
+	for _, stem := range stems {
+		sidePath, err := FindSideFile(fsys, stem)
+		if err != nil {
+			return nil, err
+		}
+		if sidePath != "" {
+			paths = append(paths, sidePath)
+		}
+	}
+	return paths, nil
+}
+
+// LoadSideFiles loads the side files of the contract filename found in dir, with the overlays
+// of the profile deeply merged over the base files. The JSON5 and YAML files are converted to
+// JSON and all the files of a contract must be written in the same format. It returns the loaded input along with the
+// source of each field, the paths of the files prefixed with root.
+func LoadSideFiles(fsys fs.FS, root string, dir string, filename string, profile string) (slangroom.SlangroomInput, InputSources, error) {
+	var input slangroom.SlangroomInput
+	sources := InputSources{Data: make(map[string]string)}
+	if err := ValidateProfile(profile); err != nil {
+		return input, sources, err
+	}
+	fields := []struct {
+		fieldName string
+		target    *string
+		source    *string
+	}{
+		{"data", &input.Data, &sources.DataFile},
+		{"keys", &input.Keys, &sources.Keys},
+		{"extra", &input.Extra, &sources.Extra},
+		{"context", &input.Context, &sources.Context},
+		{"conf", &input.Conf, &sources.Conf},
+	}
+
+	dir = filepath.ToSlash(dir)
+	if err := CheckSideFileFormats(fsys, dir, filename); err != nil {
+		return input, sources, err
+	}
+	for _, field := range fields {
+		var docs []MergeSource
+		sidePaths, err := sideFilePaths(fsys, dir, filename, field.fieldName, profile)
+		if err != nil {
+			return input, sources, err
+		}
+		for _, sidePath := range sidePaths {
+			displayPath := filepath.Join(root, filepath.FromSlash(sidePath))
+			content, err := ReadSideFile(fsys, sidePath)
+			if err != nil {
+				return input, sources, fmt.Errorf("failed to read file %s: %w", displayPath, err)
+			}
+			// Validate JSON format
+			if err := validateJSON(content); err != nil {
+				return input, sources, fmt.Errorf("invalid JSON in %s: %w", displayPath, err)
+			}
+			docs = append(docs, MergeSource{Name: displayPath, Content: string(content)})
+		}
+		if len(docs) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(docs))
+		for _, doc := range docs {
+			names = append(names, doc.Name)
+		}
+		*field.source = strings.Join(names, ", ")
+		if len(docs) == 1 {
+			// A single file is passed as is, like it was written
+			*field.target = docs[0].Content
+			continue
+		}
+		merged, _, err := mergeDocuments(docs, MergeOptions{Mode: MergeDeep}, false)
+		if err != nil {
+			return input, sources, fmt.Errorf("failed to merge %s: %w", *field.source, err)
+		}
+		*field.target = merged
+	}
+	return input, sources, nil
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadSideFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"test/hello.data.json":                  {Data: []byte(`{"user": {"name": "dev", "role": "admin"}, "url": "http://localhost"}`)},
+		"test/hello.data.staging.json":          {Data: []byte(`{"url": "https://staging.example.org"}`)},
+		"test/profiles/staging/hello.data.json": {Data: []byte(`{"user": {"name": "stage"}}`)},
+		"test/profiles/prod/hello.keys.json":    {Data: []byte(`{"keyring": {}}`)},
+		"test/hello.conf.staging.json":          {Data: []byte(`{"debug": true}`)},
+	}
+
+	t.Run("Without profile", func(t *testing.T) {
+		input, sources, err := LoadSideFiles(fsys, "root", "test", "hello", "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if input.Data != `{"user": {"name": "dev", "role": "admin"}, "url": "http://localhost"}` || input.Keys != "" || input.Conf != "" {
+			t.Errorf("Expected only the base data, got %+v", input)
+		}
+		if sources.DataFile != filepath.Join("root", "test", "hello.data.json") {
+			t.Errorf("Unexpected data source %s", sources.DataFile)
+		}
+	})
+
+	t.Run("With profile", func(t *testing.T) {
+		input, sources, err := LoadSideFiles(fsys, "", "test", "hello", "staging")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if input.Data != `{"url":"https://staging.example.org","user":{"name":"stage","role":"admin"}}` {
+			t.Errorf("Expected the overlays merged over the base data, got %s", input.Data)
+		}
+		if input.Conf != `{"debug": true}` || input.Keys != "" {
+			t.Errorf("Expected the overlay without base, got %+v", input)
+		}
+		expected := filepath.Join("test", "hello.data.json") + ", " + filepath.Join("test", "hello.data.staging.json") + ", " +
+			filepath.Join("test", "profiles", "staging", "hello.data.json")
+		if sources.DataFile != expected {
+			t.Errorf("Expected source %s, got %s", expected, sources.DataFile)
+		}
+	})
+
+	if _, _, err := LoadSideFiles(fsys, "", "test", "hello", "../prod"); err == nil {
+		t.Errorf("Expected an error for a profile with a path")
+	}
+	fsys["test/broken.data.dev.json"] = &fstest.MapFile{Data: []byte(`{"a": `)}
+	if _, _, err := LoadSideFiles(fsys, "", "test", "broken", "dev"); err == nil {
+		t.Errorf("Expected an error for an invalid overlay")
+	}
+}
@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sideFileExtensions are the extensions of the side files and of the metadata, in the order they
+// are looked up, with the format of each one
+var sideFileExtensions = []struct {
+	ext    string
+	format string
+}{
+	{".json", "JSON"},
+	{".json5", "JSON5"},
+	{".yaml", "YAML"},
+	{".yml", "YAML"},
+}
+
+// sideFileFormat returns the format of a side file or of a metadata file from its extension, an
+// empty string if the extension is not supported
+func sideFileFormat(filePath string) string {
+	ext := path.Ext(filePath)
+	for _, e := range sideFileExtensions {
+		if e.ext == ext {
+			return e.format
+		}
+	}
+	return ""
+}
+
+// FindSideFile returns the path of the side file with the given path without extension, like
+// dir/hello.data, in any of the supported formats. The path is empty if there is no such file and
+// an error is returned if the file exists in more than one format.
+func FindSideFile(fsys fs.FS, stem string) (string, error) {
+	var found []string
+	for _, e := range sideFileExtensions {
+		if info, err := fs.Stat(fsys, stem+e.ext); err == nil && !info.IsDir() {
+			found = append(found, stem+e.ext)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", nil
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("conflicting files %s: keep only one format", strings.Join(found, ", "))
+	}
+}
+
+// ReadSideFile reads a side file or a metadata file and returns its content as JSON. The JSON
+// files are returned as they were written, the JSON5 and YAML ones are converted to compact JSON.
+func ReadSideFile(fsys fs.FS, filePath string) ([]byte, error) {
+	content, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return nil, err
+	}
+	return convertSideFile(filePath, content)
+}
+
+// convertSideFile converts the content of a side file to JSON according to its extension
+func convertSideFile(filePath string, content []byte) ([]byte, error) {
+	switch sideFileFormat(filePath) {
+	case "JSON5":
+		converted, err := json5ToJSON(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON5: %w", err)
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, converted); err != nil {
+			return nil, fmt.Errorf("invalid JSON5: %w", err)
+		}
+		return compact.Bytes(), nil
+	case "YAML":
+		var document interface{}
+		if err := yaml.Unmarshal(content, &document); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		if document == nil {
+			// An empty YAML document is an empty object
+			document = map[string]interface{}{}
+		}
+		value, err := yamlToJSONValue(document)
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		converted, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		return converted, nil
+	default:
+		return content, nil
+	}
+}
+
+// yamlToJSONValue turns the maps decoded from YAML into maps with string keys
+func yamlToJSONValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			converted, err := yamlToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = converted
+		}
+		return v, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted, err := yamlToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(key)] = converted
+		}
+		return m, nil
+	case []interface{}:
+		for i, item := range v {
+			converted, err := yamlToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// CheckSideFileFormats checks that the metadata and the side files of the contract filename in
+// dir, profile overlays included, are all written in the same format.
+func CheckSideFileFormats(fsys fs.FS, dir string, filename string) error {
+	var candidates []string
+	collect := func(entriesDir string) {
+		entries, err := fs.ReadDir(fsys, entriesDir)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				candidates = append(candidates, path.Join(entriesDir, entry.Name()))
+			}
+		}
+	}
+	collect(dir)
+	if profiles, err := fs.ReadDir(fsys, path.Join(dir, "profiles")); err == nil {
+		for _, profile := range profiles {
+			if profile.IsDir() {
+				collect(path.Join(dir, "profiles", profile.Name()))
+			}
+		}
+	}
+
+	formats := make(map[string][]string)
+	for _, candidate := range candidates {
+		format := sideFileFormat(candidate)
+		name := path.Base(candidate)
+		if format == "" || !strings.HasPrefix(name, filename+".") {
+			continue
+		}
+		// The first part after the name of the contract is metadata or a field of the input
+		field := strings.SplitN(strings.TrimPrefix(name, filename+"."), ".", 2)[0]
+		if field != "metadata" && !isSideFileField(field) {
+			continue
+		}
+		formats[format] = append(formats[format], candidate)
+	}
+	if len(formats) < 2 {
+		return nil
+	}
+	var files []string
+	for _, paths := range formats {
+		files = append(files, paths...)
+	}
+	sort.Strings(files)
+	return fmt.Errorf("contract %s mixes formats in %s: write the metadata and the side files in only one format", filename, strings.Join(files, ", "))
+}
+
+// isSideFileField reports whether field is a field of the input with a side file
+func isSideFileField(field string) bool {
+	for _, side := range sideFiles {
+		if side == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadSideFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"hello.data.json":  {Data: []byte(`{"b": 1, "a": [1, 2]}`)},
+		"hello.data.yaml":  {Data: []byte("b: 1\na:\n  - 1\n  - 2\nnested:\n  1: one\n")},
+		"hello.data.json5": {Data: []byte("// the data\n{b: 1, a: [1, 2,], 'c': 'it\\'s',}\n")},
+		"empty.data.yml":   {Data: []byte("")},
+		"broken.data.yaml": {Data: []byte("a: [1, 2\n")},
+		"list.data.yaml":   {Data: []byte("- 1\n- 2\n")},
+	}
+	tests := []struct {
+		path     string
+		expected string
+		wantErr  bool
+	}{
+		{path: "hello.data.json", expected: `{"b": 1, "a": [1, 2]}`},
+		{path: "hello.data.yaml", expected: `{"a":[1,2],"b":1,"nested":{"1":"one"}}`},
+		{path: "hello.data.json5", expected: `{"b":1,"a":[1,2],"c":"it's"}`},
+		{path: "empty.data.yml", expected: `{}`},
+		{path: "list.data.yaml", expected: `[1,2]`},
+		{path: "broken.data.yaml", wantErr: true},
+		{path: "missing.data.yaml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			content, err := ReadSideFile(fsys, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadSideFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(content) != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, content)
+			}
+		})
+	}
+}
+
+func TestFindSideFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"test/hello.metadata.yml": {Data: []byte("description: hello")},
+		"test/hello.data.json":    {Data: []byte(`{}`)},
+		"test/hello.data.json5":   {Data: []byte(`{}`)},
+	}
+	if found, err := FindSideFile(fsys, "test/hello.metadata"); err != nil || found != "test/hello.metadata.yml" {
+		t.Errorf("Expected the YAML metadata, got %s, %v", found, err)
+	}
+	if found, err := FindSideFile(fsys, "test/hello.keys"); err != nil || found != "" {
+		t.Errorf("Expected no keys file, got %s, %v", found, err)
+	}
+	_, err := FindSideFile(fsys, "test/hello.data")
+	if err == nil || !strings.Contains(err.Error(), "test/hello.data.json, test/hello.data.json5") {
+		t.Errorf("Expected a conflict between the data files, got %v", err)
+	}
+}
+
+func TestCheckSideFileFormats(t *testing.T) {
+	fsys := fstest.MapFS{
+		"test/hello.metadata.yaml":             {Data: []byte("description: hello")},
+		"test/hello.data.yml":                  {Data: []byte("a: 1")},
+		"test/hello.world.data.json":           {Data: []byte(`{}`)},
+		"test/hello.notes.json":                {Data: []byte(`{}`)},
+		"test/profiles/dev/hello.keys.yaml":    {Data: []byte("k: 1")},
+		"test/mixed.data.json":                 {Data: []byte(`{}`)},
+		"test/profiles/dev/mixed.data.json5":   {Data: []byte(`{}`)},
+		"test/profiles/prod/hello.conf.yaml":   {Data: []byte("c: 1")},
+		"test/profiles/prod/unrelated.data.js": {Data: []byte(`{}`)},
+	}
+	if err := CheckSideFileFormats(fsys, "test", "hello"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	err := CheckSideFileFormats(fsys, "test", "mixed")
+	if err == nil || !strings.Contains(err.Error(), "test/mixed.data.json, test/profiles/dev/mixed.data.json5") {
+		t.Errorf("Expected an error for the mixed formats, got %v", err)
+	}
+	if _, _, err := LoadSideFiles(fsys, "", "test", "mixed", ""); err == nil {
+		t.Errorf("Expected LoadSideFiles to reject the mixed formats")
+	}
+
+	input, sources, err := LoadSideFiles(fsys, "", "test", "hello", "dev")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if input.Data != `{"a":1}` || input.Keys != `{"k":1}` {
+		t.Errorf("Expected the YAML files as JSON, got %+v", input)
+	}
+	if sources.Keys != "test/profiles/dev/hello.keys.yaml" {
+		t.Errorf("Unexpected keys source %s", sources.Keys)
+	}
+}
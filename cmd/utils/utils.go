@@ -1,57 +1,96 @@
 package utils
 
 import (
-	"embed"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
 	slangroom "github.com/dyne/slangroom-exec/bindings/go"
+	jsschema "github.com/santhosh-tekuri/jsonschema/v5"
 	"github.com/spf13/cobra"
 )
 
 // CommandMetadata contains the data from the metadata.json
 type CommandMetadata struct {
-	Description string `json:"description"`
-	Arguments   []struct {
-		Name        string                 `json:"name"`
-		Description string                 `json:"description,omitempty"`
-		Type        string                 `json:"type,omitempty"`
-		Properties  map[string]interface{} `json:"properties,omitempty"` // For complex object types
-	} `json:"arguments"`
-	Options []struct {
-		Name        string                 `json:"name"`
-		Description string                 `json:"description,omitempty"`
-		Default     string                 `json:"default,omitempty"`
-		Choices     []string               `json:"choices,omitempty"`
-		Env         []string               `json:"env,omitempty"`
-		Hidden      bool                   `json:"hidden,omitempty"`
-		File        bool                   `json:"file,omitempty"`
-		RawData     bool                   `json:"rawdata,omitempty"`
-		Type        string                 `json:"type,omitempty"`
-		Properties  map[string]interface{} `json:"properties,omitempty"` // For complex object types
-	} `json:"options"`
-	Environment map[string]string `json:"environment,omitempty"` // Map of environment variable names to values
+	Description string             `json:"description"`
+	Arguments   []ArgumentMetadata `json:"arguments"`
+	Options     []OptionMetadata   `json:"options"`
+	Environment map[string]string  `json:"environment,omitempty"` // Map of environment variable names to values
+	// Schema is a JSON Schema (draft 2020-12) describing the whole contract input
+	Schema map[string]interface{} `json:"schema,omitempty"`
+	// Secrets fill the keys of the contract from providers, see LoadSecrets
+	Secrets []SecretRef `json:"secrets,omitempty"`
+	// KeysCommand is a command, without shell, that prints the whole keys of the contract
+	KeysCommand []string `json:"keys_command,omitempty"`
+	// Interpolate replaces the placeholders of the side files and of the environment, see Interpolator
+	Interpolate bool `json:"interpolate,omitempty"`
+	// InterpolateEnv are the environment variables read by ${env:VAR} besides the TWINROOM_ ones
+	InterpolateEnv []string `json:"interpolate_env,omitempty"`
+}
+
+// ArgumentMetadata describes a positional argument in the metadata.json
+type ArgumentMetadata struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Properties  map[string]interface{} `json:"properties,omitempty"` // For complex object types
+	Items       map[string]interface{} `json:"items,omitempty"`      // Schema of the elements of variadic arguments
+	Schema      map[string]interface{} `json:"schema,omitempty"`     // JSON Schema of the argument value
+	Secret      bool                   `json:"secret,omitempty"`     // Redact the value from the logs and errors
+}
+
+// OptionMetadata describes a flag in the metadata.json
+type OptionMetadata struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Default     string                 `json:"default,omitempty"`
+	Choices     []string               `json:"choices,omitempty"`
+	Env         []string               `json:"env,omitempty"`
+	Hidden      bool                   `json:"hidden,omitempty"`
+	Required    bool                   `json:"required,omitempty"`
+	File        bool                   `json:"file,omitempty"`
+	RawData     bool                   `json:"rawdata,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Properties  map[string]interface{} `json:"properties,omitempty"` // For complex object types
+	Items       map[string]interface{} `json:"items,omitempty"`      // Schema of the elements of array types
+	Schema      map[string]interface{} `json:"schema,omitempty"`     // JSON Schema of the option value
+	Secret      bool                   `json:"secret,omitempty"`     // Redact the value from the logs and errors
 }
 
 // FlagData contains the necessary data for a given flag
 type FlagData struct {
-	Choices []string
-	Env     []string
-	File    [2]bool
+	Choices    []string
+	Env        []string
+	File       [2]bool
+	Required   bool
+	Type       string // JSON type of the value, empty for string flags
+	ItemsType  string // JSON type of the elements of array flags
+	HasDefault bool
+	// Path is the position of the value inside its object option for --option.property flags
+	Path []string
+	// PropertiesSchema is the compiled schema of the properties declared for an object option,
+	// used to validate the assembled object
+	PropertiesSchema *jsschema.Schema
 }
 
-var reservedFlag = []string{"help", "daemon"}
-var reservedShorthand = "h"
+// reservedFlags are the flags that the flags of a contract cannot shadow, the other persistent
+// flags of the root command are shadowed by the flags of the contract with the same name
+var reservedFlags = map[string]bool{"help": true, "daemon": true, "dry-run": true}
+var reservedShorthands = map[string]bool{"h": true}
 
 type codec struct {
 	Encoding string `json:"encoding"`
@@ -64,72 +103,76 @@ type codec struct {
 type Introspection map[string]codec
 
 // LoadAdditionalData loads and validates JSON data for additional fields in SlangroomInput.
+// It reads the side files of the disk without profile, see LoadSideFiles.
 func LoadAdditionalData(path string, filename string, input *slangroom.SlangroomInput) error {
+	loaded, _, err := LoadSideFiles(os.DirFS(dirOrCurrent(path)), path, ".", filename, "")
+	if err != nil {
+		return err
+	}
 	fields := []struct {
-		fieldName string
-		target    *string
+		value  string
+		target *string
 	}{
-		{"data", &input.Data},
-		{"keys", &input.Keys},
-		{"extra", &input.Extra},
-		{"context", &input.Context},
-		{"conf", &input.Conf},
+		{loaded.Data, &input.Data},
+		{loaded.Keys, &input.Keys},
+		{loaded.Extra, &input.Extra},
+		{loaded.Context, &input.Context},
+		{loaded.Conf, &input.Conf},
 	}
-
 	for _, field := range fields {
-		jsonFile := filepath.Join(path, fmt.Sprintf("%s.%s.json", filename, field.fieldName))
-		if _, err := os.Stat(jsonFile); os.IsNotExist(err) {
-			continue // Skip if file does not exist
-		}
-
-		content, err := os.ReadFile(jsonFile)
-		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", jsonFile, err)
-		}
-		// Validate JSON format
-		if err := validateJSON(content); err != nil {
-			return fmt.Errorf("invalid JSON in %s: %w", jsonFile, err)
+		if field.value != "" {
+			*field.target = field.value
 		}
-
-		*field.target = string(content)
 	}
 	return nil
 }
 
-// validateJSON checks if the provided JSON content is well-formed.
+// dirOrCurrent returns the current directory for an empty path
+func dirOrCurrent(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+// validateJSON checks if the provided JSON content is well-formed, any JSON value is accepted.
 func validateJSON(content []byte) error {
-	var temp map[string]interface{}
+	var temp interface{}
 	if err := json.Unmarshal(content, &temp); err != nil {
 		return err
 	}
 	return nil
 }
 
-// loadMetadata loads the metadata JSON file for a command, if available.
-func LoadMetadata(folder *embed.FS, path string) (*CommandMetadata, error) {
-	var file io.ReadCloser
-	var err error
-
+// loadMetadata loads the metadata file for a command, if available. The path names the JSON file,
+// the same file written in JSON5 or YAML is loaded as well.
+func LoadMetadata(folder fs.FS, path string) (*CommandMetadata, error) {
 	// Check if folder is nil to determine which file system to use
 	if folder != nil {
-		file, err = folder.Open(path)
-	} else {
-		file, err = os.Open(path)
+		return LoadMetadataFS(folder, filepath.ToSlash(path))
 	}
+	return LoadMetadataFS(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+}
 
+// LoadMetadataFS loads the metadata file at path of fsys, like LoadMetadata
+func LoadMetadataFS(fsys fs.FS, path string) (*CommandMetadata, error) {
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+	metadataPath, err := FindSideFile(fsys, stem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata file: %w", err)
+	}
+	if metadataPath == "" {
+		// Specific error message for file not found
+		return nil, fmt.Errorf("metadata file not found")
+	}
+	content, err := ReadSideFile(fsys, metadataPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			// Specific error message for file not found
-			return nil, fmt.Errorf("metadata file not found")
-		}
 		// Generic error for other types of failures
 		return nil, fmt.Errorf("failed to open metadata file: %w", err)
 	}
 
-	defer checks(file.Close)
-
 	var metadata CommandMetadata
-	if err := json.NewDecoder(file).Decode(&metadata); err != nil {
+	if err := json.Unmarshal(content, &metadata); err != nil {
 		return nil, fmt.Errorf("failed to decode metadata: %w", err)
 	}
 
@@ -144,16 +187,36 @@ func NormalizeArgumentName(name string) string {
 	// Remove [ and ] for optional arguments
 	name = strings.ReplaceAll(name, "[", "")
 	name = strings.ReplaceAll(name, "]", "")
+	// Remove ... for variadic arguments
+	name = strings.TrimSuffix(name, "...")
 	return name
 }
 
+// IsVariadicArgument reports whether an argument collects all the remaining
+// positional arguments, like <files...> or [files...]
+func IsVariadicArgument(name string) bool {
+	return strings.HasSuffix(strings.TrimRight(name, ">]"), "...")
+}
+
+// ArgumentType returns the type of an argument, variadic arguments are always arrays
+func ArgumentType(arg ArgumentMetadata) string {
+	if IsVariadicArgument(arg.Name) {
+		return "array"
+	}
+	return arg.Type
+}
+
+// ItemsType returns the JSON type of the elements of an array from its items schema,
+// the elements default to strings.
+func ItemsType(items map[string]interface{}) string {
+	if typeStr, ok := items["type"].(string); ok {
+		return jsonSchemaType(typeStr)
+	}
+	return "string"
+}
+
 // Helper function to get argument names in order from metadata
-func GetArgumentNames(arguments []struct {
-	Name        string                 `json:"name"`
-	Description string                 `json:"description,omitempty"`
-	Type        string                 `json:"type,omitempty"`
-	Properties  map[string]interface{} `json:"properties,omitempty"`
-}) []string {
+func GetArgumentNames(arguments []ArgumentMetadata) []string {
 	names := make([]string, len(arguments))
 	for i, arg := range arguments {
 		names[i] = arg.Name
@@ -161,6 +224,32 @@ func GetArgumentNames(arguments []struct {
 	return names
 }
 
+// SetArgumentValues stores the positional arguments in argContents under their metadata name,
+// converted to the JSON type declared in metadata.
+func SetArgumentValues(metadata *CommandMetadata, args []string, argContents map[string]interface{}) error {
+	for i, arg := range args {
+		if i >= len(metadata.Arguments) {
+			break
+		}
+		name := NormalizeArgumentName(metadata.Arguments[i].Name)
+		if IsVariadicArgument(metadata.Arguments[i].Name) {
+			// The last argument takes all the remaining values
+			values, err := parseItems(ItemsType(metadata.Arguments[i].Items), args[i:])
+			if err != nil {
+				return fmt.Errorf("invalid value for argument %s: %w", name, err)
+			}
+			argContents[name] = values
+			break
+		}
+		value, err := ParseTypedValue(metadata.Arguments[i].Type, arg)
+		if err != nil {
+			return fmt.Errorf("invalid value for argument %s: %w", name, err)
+		}
+		argContents[name] = value
+	}
+	return nil
+}
+
 // function to retrieve only the name of a flag
 func GetFlagName(flagStr string) string {
 	// Split the flag string by commas
@@ -191,27 +280,9 @@ func isValidChoice(value string, choices []string) bool {
 }
 
 // MergeJSON combines two JSON strings into one, with keys from the second JSON overwriting those in the first.
+// It is the same as Merge in the MergeShallow mode.
 func MergeJSON(json1, json2 string) (string, error) {
-	var map1, map2 map[string]interface{}
-
-	if err := json.Unmarshal([]byte(json1), &map1); err != nil {
-		return "", fmt.Errorf("error decoding JSON1: %v", err)
-	}
-
-	if err := json.Unmarshal([]byte(json2), &map2); err != nil {
-		return "", fmt.Errorf("error decoding JSON2: %v", err)
-	}
-
-	for key, value := range map2 {
-		map1[key] = value
-	}
-
-	mergedJSON, err := json.Marshal(map1)
-	if err != nil {
-		return "", fmt.Errorf("error encoding merged JSON: %v", err)
-	}
-
-	return string(mergedJSON), nil
+	return Merge(json1, json2, MergeOptions{Mode: MergeShallow})
 }
 
 // ConfigureArgumentsAndFlags configures the command's arguments and flags based on provided metadata,
@@ -219,22 +290,28 @@ func ConfigureArgumentsAndFlags(fileCmd *cobra.Command, metadata *CommandMetadat
 	argContents := make(map[string]interface{})
 	flagContents := make(map[string]FlagData)
 
-	requiredArgs := 0
 	// Add arguments from metadata in the order specified
 	if metadata != nil {
-		for _, arg := range metadata.Arguments {
+		var requiredArgs []string
+		for i, arg := range metadata.Arguments {
+			if IsVariadicArgument(arg.Name) && i != len(metadata.Arguments)-1 {
+				return argContents, flagContents, fmt.Errorf("only the last argument can be variadic, found %s", arg.Name)
+			}
 			argContents[NormalizeArgumentName(arg.Name)] = ""
-			if strings.HasPrefix(arg.Name, "<") && strings.HasSuffix(arg.Name, ">") {
-				requiredArgs++
-			} else {
-				fileCmd.Args = cobra.MaximumNArgs(len(metadata.Arguments))
+			if IsRequiredArgument(arg.Name) {
+				requiredArgs = append(requiredArgs, NormalizeArgumentName(arg.Name))
 			}
 		}
 
 		// Construct the fileCmd.Use string with all arguments
 		fileCmd.Use += " " + strings.Join(GetArgumentNames(metadata.Arguments), " ")
-		// Set the minimum number of required arguments
-		fileCmd.Args = cobra.MinimumNArgs(requiredArgs)
+		// Require the <arg> arguments, reporting the missing ones by name
+		fileCmd.Args = func(_ *cobra.Command, args []string) error {
+			if len(args) < len(requiredArgs) {
+				return &MissingInputError{Arguments: requiredArgs[len(args):]}
+			}
+			return nil
+		}
 
 		// Configure flags
 		for _, opt := range metadata.Options {
@@ -253,13 +330,11 @@ func ConfigureArgumentsAndFlags(fileCmd *cobra.Command, metadata *CommandMetadat
 					// Extract shorthand by removing "-" prefix
 					shorthand = strings.TrimPrefix(name, "-")
 				}
-				for _, v := range reservedFlag {
-					if v == flag {
-						return argContents, flagContents, fmt.Errorf("cannot use %s as flag name", name)
-					}
+				if reservedFlags[flag] {
+					return argContents, flagContents, fmt.Errorf("cannot use %s as flag name, --%s is reserved", name, flag)
 				}
-				if shorthand == reservedShorthand {
-					return argContents, flagContents, fmt.Errorf("cannot use %s as flag shorthand", shorthand)
+				if reservedShorthands[shorthand] {
+					return argContents, flagContents, fmt.Errorf("cannot use %s as flag shorthand, -%s is reserved", name, shorthand)
 				}
 			}
 
@@ -271,11 +346,17 @@ func ConfigureArgumentsAndFlags(fileCmd *cobra.Command, metadata *CommandMetadat
 			if opt.File {
 				description += ` ("-" for read from stdin)`
 			}
+			if opt.Required {
+				description += " (required)"
+			}
 
-			if opt.Default != "" {
-				fileCmd.Flags().StringP(flag, shorthand, opt.Default, description)
-			} else {
-				fileCmd.Flags().StringP(flag, shorthand, "", description)
+			// File flags always take a path, the type describes the file content
+			flagType := jsonSchemaType(opt.Type)
+			if opt.File || flagType == "string" {
+				flagType = ""
+			}
+			if err := addTypedFlag(fileCmd, flag, shorthand, flagType, opt.Default, description); err != nil {
+				return nil, nil, err
 			}
 			if opt.Hidden {
 				err := fileCmd.Flags().MarkHidden(flag)
@@ -283,16 +364,42 @@ func ConfigureArgumentsAndFlags(fileCmd *cobra.Command, metadata *CommandMetadat
 					return nil, nil, fmt.Errorf("error hiding a flag: %v", err)
 				}
 			}
+			if opt.Required {
+				if err := fileCmd.MarkFlagRequired(flag); err != nil {
+					return nil, nil, fmt.Errorf("error marking a flag as required: %v", err)
+				}
+			}
 
 			flagContents[flag] = FlagData{
-				Choices: opt.Choices,
-				Env:     opt.Env,
-				File:    [2]bool{opt.File, opt.RawData},
+				Choices:    opt.Choices,
+				Env:        opt.Env,
+				File:       [2]bool{opt.File, opt.RawData},
+				Required:   opt.Required,
+				Type:       flagType,
+				ItemsType:  ItemsType(opt.Items),
+				HasDefault: opt.Default != "",
 			}
 
 			if helpText != "" && description != "" {
 				fileCmd.Flags().Lookup(flag).Usage = fmt.Sprintf("%s %s", helpText, description)
 			}
+			if flagType == "object" && len(opt.Properties) > 0 {
+				flagData := flagContents[flag]
+				var err error
+				if flagData.PropertiesSchema, err = compileProperties(flag, opt.Properties); err != nil {
+					return nil, nil, err
+				}
+				flagContents[flag] = flagData
+				if err := addPropertyFlags(fileCmd, flag, nil, opt.Properties, flagContents); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		// The properties of the schema that are neither arguments nor options are flags too
+		if metadata.Schema != nil {
+			if err := addSchemaFlags(fileCmd, metadata.Schema, argContents, flagContents); err != nil {
+				return nil, nil, err
+			}
 		}
 	}
 	if introspectionData != "" {
@@ -301,10 +408,29 @@ func ConfigureArgumentsAndFlags(fileCmd *cobra.Command, metadata *CommandMetadat
 			return argContents, flagContents, fmt.Errorf("failed to parse introspection data: %v", err)
 		}
 
+		// The inputs named like a reserved flag would shadow it, they are reported all together
+		var reserved []string
+		for name := range introspection {
+			if reservedFlags[name] {
+				reserved = append(reserved, name)
+			}
+		}
+		if len(reserved) > 0 {
+			sort.Strings(reserved)
+			return argContents, flagContents, fmt.Errorf("the inputs %s of the contract cannot be flags, they are reserved: write a metadata file without them", strings.Join(reserved, ", "))
+		}
+
 		// Add fields from introspection data
 		for _, info := range introspection {
-			fileCmd.Flags().StringP(info.Name, "", "", "flag addeded from introspection")
-			flagContents[info.Name] = FlagData{}
+			flagType := introspectionFlagType(info)
+			if flagType == "string" {
+				flagType = ""
+			}
+			usage := fmt.Sprintf("The %s input of the contract", info.Name)
+			if err := addTypedFlag(fileCmd, info.Name, "", flagType, "", usage); err != nil {
+				return argContents, flagContents, err
+			}
+			flagContents[info.Name] = FlagData{Type: flagType, ItemsType: introspectionItemsType(info)}
 		}
 	}
 
@@ -315,7 +441,32 @@ func ConfigureArgumentsAndFlags(fileCmd *cobra.Command, metadata *CommandMetadat
 // sets corresponding environment variables if specified in the flag's metadata. If a flag's value
 // does not match an available choice, an error is returned.
 func ValidateFlags(cmd *cobra.Command, flagContents map[string]FlagData, argContents map[string]interface{}, input *slangroom.SlangroomInput) error {
-	for flag, content := range flagContents {
+	var missing []string
+	// Visit the flags in order, so that an object option is set before its --option.property flags
+	flags := make([]string, 0, len(flagContents))
+	for flag := range flagContents {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	for _, flag := range flags {
+		content := flagContents[flag]
+		if content.Type != "" {
+			value, ok, err := typedFlagValue(cmd, flag, content)
+			if err != nil {
+				return err
+			}
+			if ok {
+				if err := storeFlagValue(argContents, flag, content, value); err != nil {
+					return err
+				}
+			} else if content.Required {
+				missing = append(missing, flag)
+			}
+			if ok && content.Choices != nil && !isValidChoice(cmd.Flags().Lookup(flag).Value.String(), content.Choices) {
+				return fmt.Errorf("invalid input '%v' for flag: %s. Valid choices are: %v", value, flag, content.Choices)
+			}
+			continue
+		}
 		var err error
 		value, _ := cmd.Flags().GetString(flag)
 		// Check if value should be read from stdin
@@ -338,9 +489,8 @@ func ValidateFlags(cmd *cobra.Command, flagContents map[string]FlagData, argCont
 					return fmt.Errorf("invalid JSON in %s: %w", flag, err)
 				}
 				if input.Data != "" {
-					if input.Data, err = MergeJSON(input.Data, string(fileContent)); err != nil {
-						log.Println("Error encoding arguments to JSON:", err)
-						os.Exit(1)
+					if input.Data, err = Merge(input.Data, string(fileContent), mergeOptions(cmd)); err != nil {
+						return fmt.Errorf("failed to merge %s into the data: %w", flag, err)
 					}
 				} else {
 					input.Data = string(fileContent)
@@ -361,20 +511,133 @@ func ValidateFlags(cmd *cobra.Command, flagContents map[string]FlagData, argCont
 				envValue := os.Getenv(envVar)
 				if envValue != "" {
 					value = envValue
+					// A required flag set from the environment satisfies cobra's check
+					if content.Required {
+						if err := setFlagFromEnv(cmd, flag, envVar, value); err != nil {
+							return fmt.Errorf("invalid value from environment for flag %s: %w", flag, err)
+						}
+					}
 					break
 				}
 			}
 		}
 		if value != "" {
-			argContents[flag] = value
+			if err := storeFlagValue(argContents, flag, content, value); err != nil {
+				return err
+			}
+		}
+		if content.Required && value == "" && !cmd.Flags().Changed(flag) {
+			missing = append(missing, flag)
 		}
 		if (content.Choices != nil) && !isValidChoice(value, content.Choices) {
 			return fmt.Errorf("invalid input '%s' for flag: %s. Valid choices are: %v", value, flag, content.Choices)
 		}
 	}
+	// A required object option is provided by its --option.property flags as well, cobra must not
+	// report it either
+	stillMissing := missing[:0]
+	for _, flag := range missing {
+		if _, ok := argContents[flag]; ok && flagContents[flag].Type == "object" {
+			if err := cmd.Flags().SetAnnotation(flag, cobra.BashCompOneRequiredFlag, []string{"false"}); err != nil {
+				return err
+			}
+			continue
+		}
+		stillMissing = append(stillMissing, flag)
+	}
+	if len(stillMissing) > 0 {
+		return &MissingInputError{Options: stillMissing}
+	}
+	// Validate the objects assembled from the --option.property flags
+	for _, flag := range flags {
+		content := flagContents[flag]
+		if value, ok := argContents[flag]; ok && content.PropertiesSchema != nil {
+			if err := content.PropertiesSchema.Validate(value); err != nil {
+				return fmt.Errorf("invalid value for flag %s: %w", flag, err)
+			}
+		}
+	}
 	return nil
 }
 
+// MissingInputError reports the required arguments and options that were not provided.
+// It is shared by the CLI and the HTTP server so that both report the same message.
+type MissingInputError struct {
+	Arguments []string
+	Options   []string
+}
+
+func (e *MissingInputError) Error() string {
+	var parts []string
+	if len(e.Arguments) > 0 {
+		parts = append(parts, fmt.Sprintf("missing required argument(s): %s", quoteNames(e.Arguments)))
+	}
+	if len(e.Options) > 0 {
+		parts = append(parts, fmt.Sprintf("missing required option(s): %s", quoteNames(e.Options)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// IsRequiredArgument reports whether an argument name uses the <arg> convention
+func IsRequiredArgument(name string) bool {
+	return strings.HasPrefix(name, "<") && strings.HasSuffix(name, ">")
+}
+
+// RequiredInputs returns the names of the required arguments and options declared in metadata
+func RequiredInputs(metadata *CommandMetadata) (arguments []string, options []string) {
+	if metadata == nil {
+		return nil, nil
+	}
+	for _, arg := range metadata.Arguments {
+		if IsRequiredArgument(arg.Name) {
+			arguments = append(arguments, NormalizeArgumentName(arg.Name))
+		}
+	}
+	for _, opt := range metadata.Options {
+		if opt.Required {
+			options = append(options, GetFlagName(opt.Name))
+		}
+	}
+	return arguments, options
+}
+
+// CheckRequiredInputs returns a MissingInputError listing the required arguments and
+// options of the metadata that are absent or empty in data.
+func CheckRequiredInputs(metadata *CommandMetadata, data map[string]interface{}) error {
+	arguments, options := RequiredInputs(metadata)
+	missingErr := &MissingInputError{}
+	for _, name := range arguments {
+		if isEmptyInput(data[name]) {
+			missingErr.Arguments = append(missingErr.Arguments, name)
+		}
+	}
+	for _, name := range options {
+		if isEmptyInput(data[name]) {
+			missingErr.Options = append(missingErr.Options, name)
+		}
+	}
+	if len(missingErr.Arguments) > 0 || len(missingErr.Options) > 0 {
+		return missingErr
+	}
+	return nil
+}
+
+func isEmptyInput(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	str, ok := value.(string)
+	return ok && str == ""
+}
+
 // map a string representing a type to the type itself
 func MapTypeToGoType(typeStr string, elemTypeStr string) reflect.Type {
 	switch strings.ToLower(typeStr) {
@@ -514,42 +777,50 @@ func GenerateStruct(metadata CommandMetadata, introspectionData string) (interfa
 		}
 	}
 
-	// Add fields for arguments from metadata
+	// Add fields for arguments from metadata, only <arg> ones are required
 	for _, arg := range metadata.Arguments {
 		name := NormalizeArgumentName(arg.Name)
+		jsonTag := name
+		if !IsRequiredArgument(arg.Name) {
+			jsonTag += ",omitempty"
+		}
 		if arg.Type == "object" && arg.Properties != nil {
 			// Nested object handling
 			nestedFields := ParseObjectProperties(arg.Properties)
 			fields = append(fields, reflect.StructField{
 				Name: title.String(name),
 				Type: reflect.StructOf(nestedFields),
-				Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s"`, name)),
+				Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s"`, jsonTag)),
 			})
 		} else {
 			fields = append(fields, reflect.StructField{
 				Name: title.String(name),
-				Type: MapTypeToGoType(arg.Type, "unknown"),
-				Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s"`, name)),
+				Type: MapTypeToGoType(ArgumentType(arg), itemsGoType(arg.Items, "unknown")),
+				Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s"`, jsonTag)),
 			})
 		}
 	}
 
-	// Add fields for options from metadata
+	// Add fields for options from metadata, only the ones marked as required are required
 	for _, opt := range metadata.Options {
 		name := GetFlagName(opt.Name)
+		jsonTag := name
+		if !opt.Required {
+			jsonTag += ",omitempty"
+		}
 		if opt.Type == "object" && opt.Properties != nil {
 			// Nested object handling
 			nestedFields := ParseObjectProperties(opt.Properties)
 			fields = append(fields, reflect.StructField{
 				Name: title.String(name),
 				Type: reflect.StructOf(nestedFields),
-				Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s"`, name)),
+				Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s"`, jsonTag)),
 			})
 		} else if opt.File && !opt.RawData {
 			fields = append(fields, reflect.StructField{
 				Name: title.String(name),
 				Type: MapTypeToGoType(opt.Type, ""),
-				Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s" jsonschema_extras:"format=binary"`, name)),
+				Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s" jsonschema_extras:"format=binary"`, jsonTag)),
 			})
 		} else {
 			var choices string
@@ -567,8 +838,8 @@ func GenerateStruct(metadata CommandMetadata, introspectionData string) (interfa
 			}
 			fields = append(fields, reflect.StructField{
 				Name: title.String(name),
-				Type: MapTypeToGoType(opt.Type, ""),
-				Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s" jsonschema:"%s"`, name, choices)),
+				Type: MapTypeToGoType(opt.Type, itemsGoType(opt.Items, "")),
+				Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s" jsonschema:"%s"`, jsonTag, choices)),
 			})
 		}
 	}
@@ -596,6 +867,35 @@ func IsDir(path string) (bool, error) {
 	return info.IsDir(), nil
 }
 
+// introspectionCache holds the cleaned introspection of each contract, keyed by content hash
+var introspectionCache sync.Map
+
+// introspect runs the zenroom introspection of a contract, replaced in the tests
+var introspect = slangroom.Introspect
+
+// ContractHash returns the hex encoded sha256 of a contract content
+func ContractHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// IntrospectContract returns the cleaned introspection of a contract. The successful results
+// are cached by contract hash so that the same contract is introspected only once per process,
+// a failed introspection is tried again on the next call.
+func IntrospectContract(content string) (string, error) {
+	key := ContractHash(content)
+	if cached, ok := introspectionCache.Load(key); ok {
+		return cached.(string), nil
+	}
+	introspectionData, err := introspect(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to introspect contract: %w", err)
+	}
+	introspectionData = CleanIntrospection(content, introspectionData)
+	introspectionCache.Store(key, introspectionData)
+	return introspectionData, nil
+}
+
 // Utils function that removes from introspection JSON the data generated by slangroom
 func CleanIntrospection(inputStr, jsonStr string) string {
 	// Find all names between single quotes after "output into" or "output as"
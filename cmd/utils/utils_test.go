@@ -2,6 +2,7 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -154,27 +155,11 @@ func TestValidateJSON(t *testing.T) {
 func TestLoadMetadata(t *testing.T) {
 	sampleMetadata := CommandMetadata{
 		Description: "Test description",
-		Arguments: []struct {
-			Name        string                 `json:"name"`
-			Description string                 `json:"description,omitempty"`
-			Type        string                 `json:"type,omitempty"`
-			Properties  map[string]interface{} `json:"properties,omitempty"`
-		}{
+		Arguments: []ArgumentMetadata{
 			{Name: "arg1", Description: "Argument 1 description"},
 			{Name: "arg2", Description: "Argument 2 description", Type: "integer"},
 		},
-		Options: []struct {
-			Name        string                 `json:"name"`
-			Description string                 `json:"description,omitempty"`
-			Default     string                 `json:"default,omitempty"`
-			Choices     []string               `json:"choices,omitempty"`
-			Env         []string               `json:"env,omitempty"`
-			Hidden      bool                   `json:"hidden,omitempty"`
-			File        bool                   `json:"file,omitempty"`
-			RawData     bool                   `json:"rawdata,omitempty"`
-			Type        string                 `json:"type,omitempty"`
-			Properties  map[string]interface{} `json:"properties,omitempty"`
-		}{
+		Options: []OptionMetadata{
 			{Name: "--option1, -o", Description: "Option 1 description", Default: "default1", Choices: []string{"choice1", "choice2"}, Type: "string"},
 		},
 	}
@@ -265,27 +250,11 @@ func TestConfigureArgumentsAndFlags(t *testing.T) {
 
 		metadata := &CommandMetadata{
 			Description: "Test command",
-			Arguments: []struct {
-				Name        string                 `json:"name"`
-				Description string                 `json:"description,omitempty"`
-				Type        string                 `json:"type,omitempty"`
-				Properties  map[string]interface{} `json:"properties,omitempty"`
-			}{
+			Arguments: []ArgumentMetadata{
 				{Name: "<arg1>", Description: "Required argument"},
 				{Name: "[arg2]", Description: "Optional argument"},
 			},
-			Options: []struct {
-				Name        string                 `json:"name"`
-				Description string                 `json:"description,omitempty"`
-				Default     string                 `json:"default,omitempty"`
-				Choices     []string               `json:"choices,omitempty"`
-				Env         []string               `json:"env,omitempty"`
-				Hidden      bool                   `json:"hidden,omitempty"`
-				File        bool                   `json:"file,omitempty"`
-				RawData     bool                   `json:"rawdata,omitempty"`
-				Type        string                 `json:"type,omitempty"`
-				Properties  map[string]interface{} `json:"properties,omitempty"`
-			}{
+			Options: []OptionMetadata{
 				{Name: "--flag1", Description: "Test flag", Default: "default_value"},
 			},
 		}
@@ -335,6 +304,37 @@ Then print the 'love'`
 			t.Errorf("Expected argument 'integer' to be set in argContents")
 		}
 	})
+
+	t.Run("Shadowed root flags", func(t *testing.T) {
+		root := &cobra.Command{Use: "root"}
+		var output string
+		root.PersistentFlags().StringVar(&output, "output", "json", "")
+		root.PersistentFlags().String("data", "", "")
+		fileCmd := &cobra.Command{Use: "testcmd", Run: func(*cobra.Command, []string) {}}
+		root.AddCommand(fileCmd)
+
+		metadata := &CommandMetadata{Options: []OptionMetadata{{Name: "-o, --output <format>"}}}
+		if _, _, err := ConfigureArgumentsAndFlags(fileCmd, metadata, `{"data": {"encoding": "string", "name": "data", "zentype": "e"}}`); err != nil {
+			t.Fatalf("Expected the flags of the contract to shadow the root ones, got %v", err)
+		}
+		root.SetArgs([]string{"testcmd", "--output", "pdf", "--data", "bob"})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if value, _ := fileCmd.Flags().GetString("output"); value != "pdf" || output != "json" {
+			t.Errorf("Expected the flag of the contract to get the value, got %q and root %q", value, output)
+		}
+		if value, _ := fileCmd.Flags().GetString("data"); value != "bob" {
+			t.Errorf("Expected the input data to be a flag of the contract, got %q", value)
+		}
+
+		for _, name := range []string{"--dry-run", "--help"} {
+			metadata := &CommandMetadata{Options: []OptionMetadata{{Name: name}}}
+			if _, _, err := ConfigureArgumentsAndFlags(&cobra.Command{Use: "testcmd"}, metadata, ""); err == nil {
+				t.Errorf("Expected an error for the reserved flag %s", name)
+			}
+		}
+	})
 }
 
 // TestValidateFlags tests the ValidateFlags function.
@@ -511,3 +511,455 @@ func TestValidateFlags(t *testing.T) {
 		t.Errorf("Expected %s for jsonFlag, got: %v", expected, input.Data)
 	}
 }
+
+// BenchmarkIntrospectContract measures the cached introspection of an already seen contract.
+func BenchmarkIntrospectContract(b *testing.B) {
+	contract := `Given I have a 'string' named 'love'
+Then print the 'love'`
+	if _, err := IntrospectContract(contract); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := IntrospectContract(contract); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestIntrospectContractCache(t *testing.T) {
+	contract := `Given I have a 'string' named 'cached_only_on_success'`
+	calls := 0
+	failing := true
+	previous := introspect
+	introspect = func(string) (string, error) {
+		calls++
+		if failing {
+			return "", errors.New("slangroom-exec not found")
+		}
+		return `{"cached_only_on_success":{"encoding":"string","name":"cached_only_on_success","zentype":"e"}}`, nil
+	}
+	t.Cleanup(func() { introspect = previous })
+
+	if _, err := IntrospectContract(contract); err == nil {
+		t.Fatal("Expected the error of the introspection")
+	}
+	failing = false
+	introspection, err := IntrospectContract(contract)
+	if err != nil || introspection == "" {
+		t.Fatalf("Expected the introspection after a failure, got %q, %v", introspection, err)
+	}
+	if cached, err := IntrospectContract(contract); err != nil || cached != introspection || calls != 2 {
+		t.Errorf("Expected the cached introspection after %d calls, got %q, %v", calls, cached, err)
+	}
+}
+
+func TestInputSchema(t *testing.T) {
+	t.Run("No schema falls back", func(t *testing.T) {
+		_, ok := InputSchema(&CommandMetadata{Options: []OptionMetadata{{Name: "--name"}}})
+		if ok {
+			t.Errorf("Expected no schema without a schema key in metadata")
+		}
+	})
+
+	t.Run("Top level schema is used as is", func(t *testing.T) {
+		topLevel := map[string]interface{}{"type": "object", "required": []interface{}{"name"}}
+		schema, ok := InputSchema(&CommandMetadata{Schema: topLevel})
+		if !ok || schema["required"] == nil {
+			t.Errorf("Expected the top level schema, got %v", schema)
+		}
+	})
+
+	t.Run("Per field schemas are merged with legacy fields", func(t *testing.T) {
+		metadata := &CommandMetadata{
+			Arguments: []ArgumentMetadata{
+				{Name: "<user>", Schema: map[string]interface{}{"type": "string", "pattern": "^[a-z]+$"}},
+			},
+			Options: []OptionMetadata{
+				{Name: "-t, --timeout <delay>", Type: "number", Default: "60"},
+				{Name: "-d, --drink <size>", Choices: []string{"small", "large"}},
+			},
+		}
+		schema, ok := InputSchema(metadata)
+		if !ok {
+			t.Fatalf("Expected a schema")
+		}
+		properties := schema["properties"].(map[string]interface{})
+		if properties["user"].(map[string]interface{})["pattern"] != "^[a-z]+$" {
+			t.Errorf("Expected the user schema to be kept, got %v", properties["user"])
+		}
+		timeout := properties["timeout"].(map[string]interface{})
+		if timeout["type"] != "number" || timeout["default"] != 60.0 {
+			t.Errorf("Expected a number timeout with default 60, got %v", timeout)
+		}
+		if len(properties["drink"].(map[string]interface{})["enum"].([]interface{})) != 2 {
+			t.Errorf("Expected drink choices as enum, got %v", properties["drink"])
+		}
+	})
+}
+
+func TestSchemaFlags(t *testing.T) {
+	var metadata CommandMetadata
+	content := `{
+    "arguments": [{"name": "<user>"}],
+    "schema": {
+        "type": "object",
+        "required": ["user", "age"],
+        "properties": {
+            "user": {"type": "string"},
+            "age": {"type": "integer", "minimum": 0, "description": "Age of the user"},
+            "drink": {"type": "string", "enum": ["small", "large"], "default": "small"},
+            "tags": {"type": "array", "items": {"type": "integer"}},
+            "home": {"type": "object", "properties": {"city": {"type": "string"}}}
+        }
+    }
+}`
+	if err := json.Unmarshal([]byte(content), &metadata); err != nil {
+		t.Fatalf("Failed to decode metadata: %v", err)
+	}
+	cmd := &cobra.Command{Use: "testcmd"}
+	argContents, flagContents, err := ConfigureArgumentsAndFlags(cmd, &metadata, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, flag := range []string{"age", "drink", "tags", "home", "home.city"} {
+		if cmd.Flags().Lookup(flag) == nil {
+			t.Errorf("Expected the flag --%s from the schema", flag)
+		}
+	}
+	if cmd.Flags().Lookup("user") != nil {
+		t.Errorf("Expected the user property to stay an argument")
+	}
+	if usage := cmd.Flags().Lookup("age").Usage; usage != "Age of the user (required)" {
+		t.Errorf("Unexpected usage %q", usage)
+	}
+	if err := cmd.ParseFlags([]string{"--age", "30", "--tags", "1,2", "--home.city", "verona"}); err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+	if err := ValidateFlags(cmd, flagContents, argContents, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, _ := json.Marshal(argContents)
+	if string(data) != `{"age":30,"drink":"small","home":{"city":"verona"},"tags":[1,2],"user":""}` {
+		t.Errorf("Unexpected data: %s", data)
+	}
+
+	t.Run("Reserved property", func(t *testing.T) {
+		reserved := &CommandMetadata{Schema: map[string]interface{}{
+			"properties": map[string]interface{}{"help": map[string]interface{}{"type": "string"}},
+		}}
+		if _, _, err := ConfigureArgumentsAndFlags(&cobra.Command{Use: "testcmd"}, reserved, ""); err == nil {
+			t.Errorf("Expected an error for a reserved property")
+		}
+	})
+}
+
+func TestValidateInput(t *testing.T) {
+	metadata := &CommandMetadata{
+		Arguments: []ArgumentMetadata{{Name: "<user>"}, {Name: "[age]", Type: "integer"}},
+		Options:   []OptionMetadata{{Name: "--drink", Schema: map[string]interface{}{"enum": []interface{}{"small", "large"}}}},
+	}
+	schema, err := CompileInputSchema(metadata)
+	if err != nil || schema == nil {
+		t.Fatalf("Expected a compiled schema, got %v", err)
+	}
+	tests := []struct {
+		name    string
+		data    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "Valid", data: `{"user":"alice","age":30,"drink":"small"}`, args: []string{"alice", "30"}},
+		{name: "Optional argument not given", data: `{"user":"alice","age":""}`, args: []string{"alice"}},
+		{name: "Invalid choice", data: `{"user":"alice","age":"","drink":"huge"}`, args: []string{"alice"}, wantErr: true},
+		{name: "Missing argument", data: `{"age":30}`, args: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateInput(schema, tt.data, metadata, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if schema, err := CompileInputSchema(&CommandMetadata{}); err != nil || schema != nil {
+		t.Errorf("Expected no schema without JSON Schema in metadata, got %v, %v", schema, err)
+	}
+	if err := ValidateInput(nil, `{"any":"thing"}`, nil, nil); err != nil {
+		t.Errorf("Expected a nil schema to accept any data, got %v", err)
+	}
+}
+
+// TestRequiredInputs checks that the CLI and CheckRequiredInputs report the same missing inputs.
+func TestRequiredInputs(t *testing.T) {
+	metadata := &CommandMetadata{
+		Arguments: []ArgumentMetadata{
+			{Name: "<username>"},
+			{Name: "[password]"},
+		},
+		Options: []OptionMetadata{
+			{Name: "-n, --name <name>", Required: true},
+			{Name: "-r, --role <role>", Required: true, Env: []string{"TEST_REQUIRED_ROLE"}},
+			{Name: "-d, --drink <size>"},
+		},
+	}
+	cmd := &cobra.Command{Use: "testcmd"}
+	argContents, flagContents, err := ConfigureArgumentsAndFlags(cmd, metadata, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	argsErr := cmd.Args(cmd, []string{})
+	if argsErr == nil || argsErr.Error() != `missing required argument(s): "username"` {
+		t.Errorf("Unexpected arguments error: %v", argsErr)
+	}
+	if err := cmd.Args(cmd, []string{"alice"}); err != nil {
+		t.Errorf("Expected no error with the required argument, got: %v", err)
+	}
+
+	flagsErr := ValidateFlags(cmd, flagContents, argContents, nil)
+	if flagsErr == nil || flagsErr.Error() != `missing required option(s): "name", "role"` {
+		t.Errorf("Unexpected flags error: %v", flagsErr)
+	}
+
+	httpErr := CheckRequiredInputs(metadata, map[string]interface{}{"password": "secret"})
+	if httpErr == nil || httpErr.Error() != argsErr.Error()+"; "+flagsErr.Error() {
+		t.Errorf("Expected the same messages as the CLI, got: %v", httpErr)
+	}
+
+	// A required option can be provided by its environment variable
+	t.Setenv("TEST_REQUIRED_ROLE", "admin")
+	if err := cmd.Flags().Set("name", "alice"); err != nil {
+		t.Fatalf("Unexpected error setting test flag: %v", err)
+	}
+	if err := ValidateFlags(cmd, flagContents, argContents, nil); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+	if err := cmd.ValidateRequiredFlags(); err != nil {
+		t.Errorf("Expected cobra to accept the flag set from the environment, got: %v", err)
+	}
+	if err := CheckRequiredInputs(metadata, map[string]interface{}{"username": "alice", "name": "alice", "role": "admin"}); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+}
+
+// TestTypedFlags tests that options are registered and read with the type declared in metadata.
+func TestTypedFlags(t *testing.T) {
+	metadata := &CommandMetadata{
+		Arguments: []ArgumentMetadata{
+			{Name: "<count>", Type: "integer"},
+			{Name: "[label]"},
+		},
+		Options: []OptionMetadata{
+			{Name: "-t, --timeout <delay>", Type: "number", Default: "60"},
+			{Name: "-r, --retries <n>", Type: "integer", Env: []string{"TEST_TYPED_RETRIES"}},
+			{Name: "-v, --verbose", Type: "boolean"},
+			{Name: "--tags <tag>", Type: "array"},
+			{Name: "--extra <json>", Type: "object"},
+			{Name: "--unset <n>", Type: "integer"},
+		},
+	}
+	cmd := &cobra.Command{Use: "testcmd"}
+	argContents, flagContents, err := ConfigureArgumentsAndFlags(cmd, metadata, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for flag, expected := range map[string]string{"timeout": "float64", "retries": "int", "verbose": "bool", "tags": "stringSlice", "extra": "json"} {
+		if got := cmd.Flags().Lookup(flag).Value.Type(); got != expected {
+			t.Errorf("Expected flag %s of type %s, got %s", flag, expected, got)
+		}
+	}
+
+	t.Setenv("TEST_TYPED_RETRIES", "3")
+	if err := cmd.ParseFlags([]string{"-v", "--tags", "a,b", "--extra", `{"k":1}`}); err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+	if err := ValidateFlags(cmd, flagContents, argContents, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := SetArgumentValues(metadata, []string{"7", "seven"}, argContents); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := json.Marshal(argContents)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"count":7,"extra":{"k":1},"label":"seven","retries":3,"tags":["a","b"],"timeout":60,"verbose":true}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+
+	if err := SetArgumentValues(metadata, []string{"seven"}, argContents); err == nil {
+		t.Errorf("Expected an error for a non integer argument")
+	}
+}
+
+// TestArrayInputs tests repeatable array flags and variadic arguments.
+func TestArrayInputs(t *testing.T) {
+	metadata := &CommandMetadata{
+		Arguments: []ArgumentMetadata{
+			{Name: "<user>"},
+			{Name: "<ids...>", Items: map[string]interface{}{"type": "integer"}},
+		},
+		Options: []OptionMetadata{
+			{Name: "-t, --tag <tag>", Type: "array"},
+			{Name: "--weight <w>", Type: "array", Items: map[string]interface{}{"type": "number"}},
+		},
+	}
+	cmd := &cobra.Command{Use: "testcmd"}
+	argContents, flagContents, err := ConfigureArgumentsAndFlags(cmd, metadata, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := argContents["ids"]; !ok {
+		t.Errorf("Expected the variadic argument to be named ids, got %v", argContents)
+	}
+	if err := cmd.Args(cmd, []string{"alice"}); err == nil {
+		t.Errorf("Expected an error when the required variadic argument is missing")
+	}
+
+	if err := cmd.ParseFlags([]string{"--tag", "a", "-t", "b,c", "--weight", "1.5", "--weight", "2"}); err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+	if err := ValidateFlags(cmd, flagContents, argContents, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := SetArgumentValues(metadata, []string{"alice", "1", "2", "3"}, argContents); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := json.Marshal(argContents)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"ids":[1,2,3],"tag":["a","b","c"],"user":"alice","weight":[1.5,2]}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+
+	t.Run("Introspected arrays", func(t *testing.T) {
+		introspection := `{"amounts": {"encoding": "float", "name": "amounts", "zentype": "a"}, "names": {"encoding": "string", "name": "names", "zentype": "a"}}`
+		cmd := &cobra.Command{Use: "introspected"}
+		argContents, flagContents, err := ConfigureArgumentsAndFlags(cmd, nil, introspection)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := cmd.ParseFlags([]string{"--amounts", "1.5,2", "--names", "a,1"}); err != nil {
+			t.Fatalf("Unexpected error parsing flags: %v", err)
+		}
+		if err := ValidateFlags(cmd, flagContents, argContents, nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data, _ := json.Marshal(argContents)
+		if string(data) != `{"amounts":[1.5,2],"names":["a","1"]}` {
+			t.Errorf("Expected the elements with the type of their encoding, got %s", data)
+		}
+	})
+
+	t.Run("Variadic argument must be the last one", func(t *testing.T) {
+		invalid := &CommandMetadata{Arguments: []ArgumentMetadata{{Name: "[files...]"}, {Name: "<user>"}}}
+		if _, _, err := ConfigureArgumentsAndFlags(&cobra.Command{Use: "invalid"}, invalid, ""); err == nil {
+			t.Errorf("Expected an error for a variadic argument that is not the last one")
+		}
+	})
+}
+
+// TestObjectPropertyFlags tests that object options are assembled from --option.property flags.
+func TestObjectPropertyFlags(t *testing.T) {
+	metadata := &CommandMetadata{
+		Options: []OptionMetadata{
+			{
+				Name: "--love",
+				Type: "object",
+				Properties: map[string]interface{}{
+					"male": map[string]interface{}{"type": "string"},
+					"sole": map[string]interface{}{"type": "string"},
+					"age":  map[string]interface{}{"type": "integer", "minimum": 0},
+					"home": map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}},
+					},
+				},
+			},
+		},
+	}
+	newCommand := func(t *testing.T, args ...string) (*cobra.Command, map[string]interface{}, map[string]FlagData) {
+		cmd := &cobra.Command{Use: "testcmd"}
+		argContents, flagContents, err := ConfigureArgumentsAndFlags(cmd, metadata, "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := cmd.ParseFlags(args); err != nil {
+			t.Fatalf("Unexpected error parsing flags: %v", err)
+		}
+		return cmd, argContents, flagContents
+	}
+
+	t.Run("Dotted flags", func(t *testing.T) {
+		cmd, argContents, flagContents := newCommand(t, "--love.male", "romeo", "--love.age", "16", "--love.home.city", "verona")
+		if err := ValidateFlags(cmd, flagContents, argContents, nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data, _ := json.Marshal(argContents["love"])
+		if string(data) != `{"age":16,"home":{"city":"verona"},"male":"romeo"}` {
+			t.Errorf("Unexpected object: %s", data)
+		}
+	})
+
+	t.Run("File with dotted override", func(t *testing.T) {
+		jsonFile := filepath.Join(t.TempDir(), "love.json")
+		if err := os.WriteFile(jsonFile, []byte(`{"male":"romeo","sole":"juliet"}`), 0600); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		cmd, argContents, flagContents := newCommand(t, "--love=@"+jsonFile, "--love.sole", "rosaline")
+		if err := ValidateFlags(cmd, flagContents, argContents, nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data, _ := json.Marshal(argContents["love"])
+		if string(data) != `{"male":"romeo","sole":"rosaline"}` {
+			t.Errorf("Unexpected object: %s", data)
+		}
+	})
+
+	t.Run("Invalid properties", func(t *testing.T) {
+		cmd, argContents, flagContents := newCommand(t, "--love", `{"male":1}`)
+		if err := ValidateFlags(cmd, flagContents, argContents, nil); err == nil {
+			t.Errorf("Expected an error for a property of the wrong type")
+		}
+		cmd, argContents, flagContents = newCommand(t, "--love.age", "-1")
+		if err := ValidateFlags(cmd, flagContents, argContents, nil); err == nil {
+			t.Errorf("Expected an error for a property below its minimum")
+		}
+	})
+
+	t.Run("Required object from dotted flags", func(t *testing.T) {
+		required := &CommandMetadata{Options: []OptionMetadata{{
+			Name:       "--love",
+			Type:       "object",
+			Required:   true,
+			Properties: map[string]interface{}{"male": map[string]interface{}{"type": "string"}},
+		}}}
+		run := func(args ...string) error {
+			cmd := &cobra.Command{Use: "testcmd", Run: func(*cobra.Command, []string) {}}
+			argContents, flagContents, err := ConfigureArgumentsAndFlags(cmd, required, "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			cmd.PreRunE = func(cmd *cobra.Command, _ []string) error {
+				return ValidateFlags(cmd, flagContents, argContents, nil)
+			}
+			cmd.SetArgs(args)
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return cmd.Execute()
+		}
+		if err := run("--love.male", "romeo"); err != nil {
+			t.Errorf("Expected the dotted flag to provide the object, got %v", err)
+		}
+		var missingErr *MissingInputError
+		if err := run(); !errors.As(err, &missingErr) || missingErr.Options[0] != "love" {
+			t.Errorf("Expected the object to be missing, got %v", err)
+		}
+	})
+}
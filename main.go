@@ -2,16 +2,25 @@ package main
 
 import (
 	"embed"
+	"io/fs"
 
 	"github.com/forkbombeu/twinroom/cmd"
 )
 
-//go:generate go run scripts/replaceContracts.go
+//go:generate go run scripts/bundleContracts.go
 
 //go:embed contracts
 var contracts embed.FS
 
+// bundle holds the contracts of extra_dir.json when go generate has written contracts_bundle.go,
+// it is embedded in place of the contracts folder
+var bundle fs.FS
+
 func main() {
 	// Initialize CLI with embedded contracts
+	if bundle != nil {
+		cmd.Execute(bundle)
+		return
+	}
 	cmd.Execute(contracts)
 }
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/forkbombeu/twinroom/cmd/utils"
+)
+
+const (
+	// Path to the contracts folder embedded by main.go
+	contractsDir = "contracts"
+	// Name of the overlay file written in the output folder
+	overlayFile = "overlay.json"
+	// Name of the Go file that embeds the bundle, main.go uses it in place of the contracts folder
+	bundleGoFile = "contracts_bundle.go"
+)
+
+// bundleGoTemplate is the Go file that embeds the contracts folder of the bundle
+const bundleGoTemplate = `// Code generated by scripts/bundleContracts.go; DO NOT EDIT.
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed %[2]q
+var bundleFiles embed.FS
+
+func init() {
+	var err error
+	if bundle, err = fs.Sub(bundleFiles, %[1]q); err != nil {
+		panic(err)
+	}
+}
+`
+
+// sideFields are the side files loaded along with a contract, along with its metadata
+var sideFields = []string{"metadata", "data", "keys", "extra", "context", "conf"}
+
+// sideExtensions are the formats of the side files
+var sideExtensions = []string{".json", ".json5", ".yaml", ".yml"}
+
+// ContractsConfig represents the structure of the JSON file that contains the paths.
+type ContractsConfig struct {
+	Paths []ContractsSource `json:"paths"`
+}
+
+// ContractsSource is a folder of contracts to embed, mounted at Mount inside the contracts
+// folder. In extra_dir.json it is either the path of the folder or an object with path and mount.
+type ContractsSource struct {
+	Path  string `json:"path"`
+	Mount string `json:"mount,omitempty"`
+}
+
+// UnmarshalJSON accepts the path of the folder as a plain string
+func (s *ContractsSource) UnmarshalJSON(b []byte) error {
+	var p string
+	if err := json.Unmarshal(b, &p); err == nil {
+		*s = ContractsSource{Path: p}
+		return nil
+	}
+	type source ContractsSource
+	var src source
+	if err := json.Unmarshal(b, &src); err != nil {
+		return err
+	}
+	*s = ContractsSource(src)
+	return nil
+}
+
+// bundleContracts writes the contracts and the side files found in the sources, or in the
+// contracts folder without sources, in the contracts folder of out, keeping their tree, together
+// with their manifest and the Go file that embeds them. With overlay the Go file is written in out
+// along with the overlay that makes go build read it, so that bundles with different
+// configurations can be built in parallel. Nothing is written in the contracts folder itself.
+func bundleContracts(config ContractsConfig, out string, overlay bool) error {
+	sources := config.Paths
+	if len(sources) == 0 {
+		sources = []ContractsSource{{Path: contractsDir}}
+	}
+	// The bundle is embedded from the folder of main.go, so it must be inside of it
+	module, err := filepath.Abs(".")
+	if err != nil {
+		return err
+	}
+	outAbs, err := filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	embedPath, err := filepath.Rel(module, outAbs)
+	if err != nil || !filepath.IsLocal(embedPath) {
+		return fmt.Errorf("the output folder %s must be inside of the module to be embedded", out)
+	}
+	// Collect the files first, so that nothing is written if a source is not valid
+	files, err := collectContracts(sources)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no contracts found in the folders to embed")
+	}
+	if err := copyContracts(files, filepath.Join(out, contractsDir)); err != nil {
+		return err
+	}
+
+	// The manifest describes the bundled contracts and sits in the root of them
+	manifest, err := utils.BuildManifest(os.DirFS(out), contractsDir)
+	if err != nil {
+		return err
+	}
+	manifestContent, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(out, contractsDir, utils.ManifestFile), manifestContent, 0600); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	embedPath = filepath.ToSlash(embedPath)
+	goContent := fmt.Sprintf(bundleGoTemplate, embedPath, path.Join(embedPath, contractsDir))
+	if !overlay {
+		if err := os.WriteFile(bundleGoFile, []byte(goContent), 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", bundleGoFile, err)
+		}
+		return nil
+	}
+	// The Go file of the output folder has no .go extension, so that go build ./... skips it
+	goFile, err := filepath.Abs(filepath.Join(out, bundleGoFile+".overlay"))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(goFile, []byte(goContent), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", goFile, err)
+	}
+	target, err := filepath.Abs(bundleGoFile)
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(Overlay{Replace: map[string]string{target: goFile}}, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(out, overlayFile), content, 0600); err != nil {
+		return fmt.Errorf("failed to write overlay: %w", err)
+	}
+	return nil
+}
+
+// Overlay is the file read by go build -overlay, it maps the Go file that embeds the bundle to the
+// one of an output folder
+type Overlay struct {
+	Replace map[string]string `json:"Replace"`
+}
+
+// collectContracts returns the files to copy, from their path in the contracts folder to their
+// source path, and fails on the files that two sources would write at the same path
+func collectContracts(sources []ContractsSource) (map[string]string, error) {
+	files := make(map[string]string)
+	var collisions []string
+	for _, src := range sources {
+		if src.Mount != "" && !filepath.IsLocal(src.Mount) {
+			return nil, fmt.Errorf("invalid mount %s for %s: it must be a relative path inside the contracts folder", src.Mount, src.Path)
+		}
+		mount := filepath.ToSlash(filepath.Clean(src.Mount))
+		srcFS := os.DirFS(src.Path)
+		err := fs.WalkDir(srcFS, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !isContractFile(srcFS, p) {
+				return nil
+			}
+			dest := path.Join(mount, p)
+			srcFile := filepath.Join(src.Path, filepath.FromSlash(p))
+			if previous, exists := files[dest]; exists {
+				collisions = append(collisions, fmt.Sprintf("%s from %s and %s", dest, previous, srcFile))
+				return nil
+			}
+			files[dest] = srcFile
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read source directory %s: %w", src.Path, err)
+		}
+	}
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return nil, fmt.Errorf("files with the same name in different sources, use a mount to keep them apart:\n  %s", strings.Join(collisions, "\n  "))
+	}
+	return files, nil
+}
+
+// isContractFile reports whether p is a contract or a side file of a contract of fsys: the
+// side files sit next to the contract or in profiles/<profile>/ next to it.
+func isContractFile(fsys fs.FS, p string) bool {
+	name := path.Base(p)
+	if path.Ext(name) == ".slang" {
+		return true
+	}
+	ext := path.Ext(name)
+	known := false
+	for _, e := range sideExtensions {
+		known = known || e == ext
+	}
+	if !known {
+		return false
+	}
+	dir := path.Dir(p)
+	if parent := path.Dir(dir); path.Base(parent) == "profiles" {
+		// profiles/<profile>/<contract>.<field>.<ext>
+		dir = path.Dir(parent)
+	}
+	// <contract>.<field>[.<profile>].<ext>
+	parts := strings.Split(strings.TrimSuffix(name, ext), ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		for _, field := range sideFields {
+			if parts[i] != field {
+				continue
+			}
+			contract := path.Join(dir, strings.Join(parts[:i], ".")+".slang")
+			if _, err := fs.Stat(fsys, contract); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// copyContracts writes the files in the bundle folder, keeping their tree. A stale bundle is
+// removed first.
+func copyContracts(files map[string]string, bundleDir string) error {
+	contractsAbs, err := filepath.Abs(contractsDir)
+	if err != nil {
+		return err
+	}
+	bundleAbs, err := filepath.Abs(bundleDir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(contractsAbs, bundleAbs); err != nil || filepath.IsLocal(rel) || rel == "." {
+		return fmt.Errorf("the output folder %s must be outside of the contracts folder", bundleDir)
+	}
+	if err := os.RemoveAll(bundleDir); err != nil {
+		return fmt.Errorf("failed to remove the previous bundle: %w", err)
+	}
+
+	for dest, srcFile := range files {
+		destFile := filepath.Join(bundleAbs, filepath.FromSlash(dest))
+		if err := os.MkdirAll(filepath.Dir(destFile), 0750); err != nil {
+			return fmt.Errorf("failed to create bundle folder: %w", err)
+		}
+		input, err := os.ReadFile(srcFile) // #nosec G304 -- the sources come from extra_dir.json
+		if err != nil {
+			return fmt.Errorf("failed to read source file %s: %w", srcFile, err)
+		}
+		if err := os.WriteFile(destFile, input, 0600); err != nil {
+			return fmt.Errorf("failed to write file to bundle: %w", err)
+		}
+	}
+	return nil
+}
+
+func main() {
+	configFile := flag.String("config", "extra_dir.json", "JSON file with the folders of contracts to embed")
+	out := flag.String("out", filepath.Join("out", "bundle"), "Output folder of the bundle, inside of the module")
+	overlay := flag.Bool("overlay", false, "Write the Go file that embeds the bundle in the output folder, with the overlay.json to pass to go build -overlay, in place of "+bundleGoFile)
+	flag.Parse()
+
+	// Parse the config file with paths, without it the contracts folder is embedded as is
+	var config ContractsConfig
+	content, err := os.ReadFile(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s not found, use default contracts folder.\n", *configFile)
+	} else if err := json.Unmarshal(content, &config); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s format not correct, use default contract folder.\n", *configFile)
+		config = ContractsConfig{}
+	}
+
+	if err := bundleContracts(config, *out, *overlay); err != nil {
+		fmt.Printf("Error bundling contracts: %v\n", err)
+		os.Exit(1)
+	}
+}